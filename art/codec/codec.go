@@ -0,0 +1,71 @@
+// Package codec decodes and encodes text art. A block like [5 #] stands for the symbol after the space repeated as
+// many times as the count says, everything outside of the brackets is written as it is.
+package codec
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MaxDecodedLength is the most bytes Decode writes, a block that would take the art past it has a BadCountError. It
+// keeps a short text like [100000000000 #] from asking for a hundred gigabytes.
+const MaxDecodedLength = 1 << 24
+
+// Options holds the settings of Encode, the zero value gives the default encoding.
+type Options struct {
+	MaxUnit     int  // Longest repeating unit in characters, zero means DefaultMaxUnit. One only compresses runs of a character.
+	AcrossLines bool // Let a block take in line breaks, by default every line is encoded on its own.
+}
+
+// Decode expands every [count symbol] block of the text, which can span several lines. The first malformed block is
+// returned as one of the typed errors, which tell the Position of the problem.
+func Decode(text string) (string, error) {
+	var decoded strings.Builder
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case ']':
+			return "", &UnbalancedBracketError{Position: position(text, i), Bracket: "]"}
+		case '[':
+			end := strings.IndexAny(text[i+1:], "[]")
+			if end < 0 || text[i+1+end] == '[' {
+				return "", &UnbalancedBracketError{Position: position(text, i), Bracket: "["}
+			}
+			end += i + 1
+			block, err := decodeBlock(text, i, end, MaxDecodedLength-decoded.Len())
+			if err != nil {
+				return "", err
+			}
+			decoded.WriteString(block)
+			i = end + 1
+		default:
+			decoded.WriteByte(text[i])
+			i++
+		}
+	}
+	return decoded.String(), nil
+}
+
+// decodeBlock expands the block that starts with the bracket at text[start] and ends with the one at text[end]. The
+// expanded block can be at most room bytes long.
+func decodeBlock(text string, start, end, room int) (string, error) {
+	digits := start + 1
+	for digits < end && text[digits] >= '0' && text[digits] <= '9' {
+		digits++
+	}
+	count, err := strconv.Atoi(text[start+1 : digits])
+	if err != nil {
+		return "", &BadCountError{Position: position(text, start+1), Count: text[start+1 : digits]}
+	}
+	if digits == end || text[digits] != ' ' {
+		return "", &MissingSpaceError{Position: position(text, digits)}
+	}
+	symbol := text[digits+1 : end]
+	if symbol == "" {
+		return "", &EmptySymbolError{Position: position(text, digits+1)}
+	}
+	if count > room/len(symbol) {
+		return "", &BadCountError{Position: position(text, start+1), Count: text[start+1 : digits]}
+	}
+	return strings.Repeat(symbol, count), nil
+}
@@ -0,0 +1,170 @@
+package codec
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    string
+	}{
+		{"[5 #][5 -_]-[5 #]", "#####-_-_-_-_-_-#####"},
+		{"plain text", "plain text"},
+		{"[3  a]", " a a a"},
+		{"[0 x]y", "y"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		got, err := Decode(test.encoded)
+		if err != nil || got != test.want {
+			t.Errorf("Decode(%q) = %q, %v, want %q", test.encoded, got, err, test.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    error
+	}{
+		{"ab[5 #", &UnbalancedBracketError{Position: Position{Offset: 2, Line: 1, Column: 3}, Bracket: "["}},
+		{"][", &UnbalancedBracketError{Position: Position{Offset: 0, Line: 1, Column: 1}, Bracket: "]"}},
+		{"[5 [#]]", &UnbalancedBracketError{Position: Position{Offset: 0, Line: 1, Column: 1}, Bracket: "["}},
+		{"[5 #]\n[3 -]\nöö[3-_]", &MissingSpaceError{Position: Position{Offset: 18, Line: 3, Column: 5}}},
+		{"x[ab]", &BadCountError{Position: Position{Offset: 2, Line: 1, Column: 3}}},
+		{"[99999999999999999999 a]", &BadCountError{Position: Position{Offset: 1, Line: 1, Column: 2}, Count: "99999999999999999999"}},
+		{"[9223372036854775807 ab]", &BadCountError{Position: Position{Offset: 1, Line: 1, Column: 2}, Count: "9223372036854775807"}},
+		{"ab[100000000000 #]", &BadCountError{Position: Position{Offset: 3, Line: 1, Column: 4}, Count: "100000000000"}},
+		{"[16777216 #][1 #]", &BadCountError{Position: Position{Offset: 13, Line: 1, Column: 14}, Count: "1"}},
+		{"[5 ]", &EmptySymbolError{Position: Position{Offset: 3, Line: 1, Column: 4}}},
+	}
+	for _, test := range tests {
+		if _, err := Decode(test.encoded); !reflect.DeepEqual(err, test.want) {
+			t.Errorf("Decode(%q) returned %v, want %v", test.encoded, err, test.want)
+		}
+	}
+
+	_, err := Decode("[5 #]\n[3 -]\nöö[3-_]")
+	if want := "line 3, col 5: expected space after count"; err == nil || err.Error() != want {
+		t.Errorf("error message %q, want %q", err, want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := map[string]string{
+		"aaaaabbbbb":                           "[5 a][5 b]",
+		"ababab":                               "[3 ab]",
+		"abc":                                  "abc",
+		"aa":                                   "aa",
+		"/\\_/\\_/\\_/\\_":                     "[4 /\\_]",
+		"x/\\_/\\_/\\_/\\_x":                   "x[4 /\\_]x",
+		"ab[ab][ab]":                           "ab[ab][ab]",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaab": "[35 a]b",
+	}
+	for decoded, want := range tests {
+		if got := Encode(decoded, Options{}); got != want {
+			t.Errorf("Encode(%q) = %q, want %q", decoded, got, want)
+		}
+		if !strings.Contains(decoded, "[") {
+			if again, err := Decode(want); err != nil || again != decoded {
+				t.Errorf("Decode(%q) = %q, %v, want %q", want, again, err, decoded)
+			}
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	tests := map[string]string{
+		"█████":                               "[5 █]",
+		"╔═════╗":                             "╔[5 ═]╗",
+		"░▒▓░▒▓░▒▓":                           "[3 ░▒▓]",
+		"🙂🙂🙂🙂🙂":                               "[5 🙂]",
+		"👍🏽👍🏽👍🏽👍🏽👍🏽":                          "[5 👍🏽]",
+		"👨\u200d👩\u200d👧👨\u200d👩\u200d👧":      "👨\u200d👩\u200d👧👨\u200d👩\u200d👧",
+		"e\u0301e\u0301e\u0301e\u0301e\u0301": "[5 e\u0301]",
+	}
+	for decoded, want := range tests {
+		if got := Encode(decoded, Options{}); got != want {
+			t.Errorf("Encode(%q) = %q, want %q", decoded, got, want)
+		}
+		if again, err := Decode(want); err != nil || again != decoded {
+			t.Errorf("Decode(%q) = %q, %v, want %q", want, again, err, decoded)
+		}
+	}
+	if got := Ratio("█████", "[5 █]"); got != 1 {
+		t.Errorf("Ratio counts bytes, got %v, want 1", got)
+	}
+}
+
+func TestEncodeMaxUnit(t *testing.T) {
+	art := "/\\_/\\_/\\_/\\_"
+	if got := Encode(art, Options{MaxUnit: 2}); got != art {
+		t.Errorf("Encode(%q) with MaxUnit 2 = %q, want %q", art, got, art)
+	}
+	if got := Encode("aaaaaabcabcabcabc", Options{MaxUnit: 1}); got != "[6 a]bcabcabcabc" {
+		t.Errorf("Encode with MaxUnit 1 = %q", got)
+	}
+	if got := Encode("aaaaab", Options{MaxUnit: 200000000}); got != "[5 a]b" {
+		t.Errorf("Encode with a huge MaxUnit = %q, want %q", got, "[5 a]b")
+	}
+	if got, want := Ratio(art, "[4 /\\_]"), 12.0/7; got != want {
+		t.Errorf("Ratio = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeAcrossLines(t *testing.T) {
+	art := "ab\nab\nab\n"
+	if got := Encode(art, Options{}); got != art {
+		t.Errorf("Encode(%q) = %q, want every line on its own", art, got)
+	}
+	if got, want := Encode(art, Options{AcrossLines: true}), "[3 ab\n]"; got != want {
+		t.Errorf("Encode(%q) across lines = %q, want %q", art, got, want)
+	}
+	if got, want := Encode("#####\r\n\r\n-----", Options{}), "[5 #]\r\n\r\n[5 -]"; got != want {
+		t.Errorf("Encode with CRLF = %q, want %q", got, want)
+	}
+}
+
+func TestLines(t *testing.T) {
+	art := "##########\n\n  ═════\r\n-----"
+	var encoded strings.Builder
+	stats, err := EncodeLines(strings.NewReader(art), &encoded, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[10 #]\n\n  [5 ═]\r\n[5 -]"; encoded.String() != want {
+		t.Errorf("EncodeLines = %q, want %q", encoded.String(), want)
+	}
+	if want := (Stats{Art: 26, Encoded: 22}); stats != want {
+		t.Errorf("EncodeLines stats = %+v, want %+v", stats, want)
+	}
+
+	var decoded strings.Builder
+	if err := DecodeLines(strings.NewReader(encoded.String()), &decoded); err != nil || decoded.String() != art {
+		t.Errorf("DecodeLines = %q, %v, want %q", decoded.String(), err, art)
+	}
+
+	err = DecodeLines(strings.NewReader("[5 #]\n[3 -]\nöö[3-_]"), &decoded)
+	want := &MissingSpaceError{Position: Position{Offset: 18, Line: 3, Column: 5}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("DecodeLines error = %#v, want %#v", err, want)
+	}
+}
+
+func TestEncodeLongRun(t *testing.T) {
+	art := strings.Repeat("#", 200000) + strings.Repeat("/\\_", 100000)
+	done := make(chan string)
+	go func() { done <- Encode(art, Options{}) }()
+	select {
+	case got := <-done:
+		if want := "[200000 #][100000 /\\_]"; got != want {
+			t.Errorf("Encode of a long run = %q, want %q", got, want)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Encode of a long run took longer than 10s")
+	}
+}
@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxUnit is the longest repeating unit Encode looks for when Options does not say.
+const DefaultMaxUnit = 8
+
+// The zero width joiner glues emoji like the family or the rainbow flag into one character.
+const zeroWidthJoiner = '\u200d'
+
+// step is how the shortest encoding of the art from some character starts, unit is zero for a plain character.
+type step struct {
+	unit  int
+	count int
+}
+
+// Encode turns repeated runs of the art into [count symbol] blocks, the reverse of Decode. Every unit of one up to
+// Options.MaxUnit characters is tried at every position and the combination of blocks and plain characters that gives
+// the shortest output is picked, blockCounts tells which counts of a run are tried. When a block is as long as the
+// characters it replaces the block is written. Brackets can not be put in a block, so they are always written as they
+// are. Unless Options.AcrossLines is set every line is encoded on its own.
+func Encode(art string, options Options) string {
+	if !options.AcrossLines {
+		return encodeLines(art, options)
+	}
+	return encodeText(art, options)
+}
+
+// encodeText encodes the art as one text, line breaks are characters like any other.
+func encodeText(art string, options Options) string {
+	maxUnit := options.MaxUnit
+	if maxUnit <= 0 {
+		maxUnit = DefaultMaxUnit
+	}
+	chars := characters(art)
+	length := len(chars)
+	// No unit is longer than the art, so a huge MaxUnit does not make repeatCounts allocate for units that cannot be.
+	maxUnit = min(maxUnit, length)
+	repeats := repeatCounts(chars, maxUnit)
+
+	// cost[i] is the length of the shortest encoding of chars[i:], filled in from the end of the art.
+	cost := make([]int, length+1)
+	steps := make([]step, length)
+	var counts []int
+	for i := length - 1; i >= 0; i-- {
+		cost[i] = 1 + cost[i+1]
+		for unit := 1; unit <= maxUnit && i+unit <= length; unit++ {
+			if last := chars[i+unit-1]; last == "[" || last == "]" {
+				break
+			}
+			counts = blockCounts(counts[:0], repeats[unit][i], maxUnit)
+			for _, count := range counts {
+				total := blockLength(unit, count) + cost[i+unit*count]
+				// On a tie a block beats plain characters and a longer run beats a shorter one of the same unit, so
+				// ten # become [10 #] and not [9 #]#.
+				if total < cost[i] || (total == cost[i] && (steps[i].unit == 0 || steps[i].unit == unit)) {
+					cost[i] = total
+					steps[i] = step{unit: unit, count: count}
+				}
+			}
+		}
+	}
+
+	var encoded strings.Builder
+	for i := 0; i < length; {
+		if steps[i].unit == 0 {
+			encoded.WriteString(chars[i])
+			i++
+			continue
+		}
+		unit, count := steps[i].unit, steps[i].count
+		encoded.WriteString("[" + strconv.Itoa(count) + " " + strings.Join(chars[i:i+unit], "") + "]")
+		i += unit * count
+	}
+	return encoded.String()
+}
+
+// characters splits the art into what is seen as one character, so a unit never cuts one in half. That is a rune
+// together with the combining marks, variation selectors and skin tones after it, and emoji glued with a zero width
+// joiner.
+func characters(art string) []string {
+	var chars []string
+	joined := false
+	for i, r := range art {
+		if len(chars) > 0 && (joined || attaches(r)) {
+			chars[len(chars)-1] += string(r)
+		} else {
+			chars = append(chars, art[i:i+len(string(r))])
+		}
+		joined = r == zeroWidthJoiner
+	}
+	return chars
+}
+
+// attaches tells whether the rune belongs to the character in front of it.
+func attaches(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Variation_Selector) || r == zeroWidthJoiner ||
+		(r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// repeatCounts tells for every unit length and position how many times chars[i:i+unit] repeats back to back from
+// there.
+func repeatCounts(chars []string, maxUnit int) [][]int {
+	repeats := make([][]int, maxUnit+1)
+	for unit := 1; unit <= maxUnit; unit++ {
+		repeats[unit] = make([]int, len(chars))
+		for i := len(chars) - unit; i >= 0; i-- {
+			repeats[unit][i] = 1
+			if i+2*unit <= len(chars) && sameUnit(chars[i:i+unit], chars[i+unit:i+2*unit]) {
+				repeats[unit][i] = repeats[unit][i+unit] + 1
+			}
+		}
+	}
+	return repeats
+}
+
+func sameUnit(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// blockCounts appends the counts worth trying for a unit that repeats run times, smallest first. Trying every count
+// would make a long run quadratic, so only the counts where a block is one digit shorter and the last maxUnit counts of
+// the run are tried. The last ones let the end of the run join what comes after it.
+func blockCounts(counts []int, run, maxUnit int) []int {
+	tail := max(2, run-maxUnit)
+	for count := 9; count < tail; count = count*10 + 9 {
+		counts = append(counts, count)
+	}
+	for count := tail; count <= run; count++ {
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+// blockLength is the length of a [count symbol] block in characters.
+func blockLength(unit, count int) int {
+	return len(strconv.Itoa(count)) + unit + 3
+}
+
+// Length is the number of characters of the text, counted the way Encode counts them.
+func Length(text string) int {
+	return len(characters(text))
+}
+
+// Ratio is how many times shorter the encoded text is than the art, 2 means it takes half the space.
+func Ratio(art, encoded string) float64 {
+	return Stats{Art: Length(art), Encoded: Length(encoded)}.Ratio()
+}
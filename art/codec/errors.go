@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is where in the encoded text a problem was found.
+type Position struct {
+	Offset int // Byte offset, counted from 0.
+	Line   int // Line, counted from 1.
+	Column int // Character of the line, counted from 1.
+}
+
+// position finds the line and column of a byte offset of the text.
+func position(text string, offset int) Position {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	return Position{
+		Offset: offset,
+		Line:   strings.Count(text[:offset], "\n") + 1,
+		Column: Length(text[start:offset]) + 1,
+	}
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, col %d", p.Line, p.Column)
+}
+
+// UnbalancedBracketError is returned for a "[" that is not closed before the next "[" or the end of the text, and for
+// a "]" that was never opened.
+type UnbalancedBracketError struct {
+	Position
+	Bracket string // The bracket without a pair.
+}
+
+func (e *UnbalancedBracketError) Error() string {
+	if e.Bracket == "]" {
+		return fmt.Sprintf("%s: unexpected ] without a [", e.Position)
+	}
+	return fmt.Sprintf("%s: expected ] to close the [", e.Position)
+}
+
+// MissingSpaceError is returned when the count of a block is not followed by a space.
+type MissingSpaceError struct {
+	Position
+}
+
+func (e *MissingSpaceError) Error() string {
+	return fmt.Sprintf("%s: expected space after count", e.Position)
+}
+
+// BadCountError is returned when a block does not start with a count that fits in an int, or when the count would take
+// the art past MaxDecodedLength.
+type BadCountError struct {
+	Position
+	Count string // The count as it was written, empty when there was none.
+}
+
+func (e *BadCountError) Error() string {
+	if e.Count == "" {
+		return fmt.Sprintf("%s: expected count after [", e.Position)
+	}
+	return fmt.Sprintf("%s: count %s is too large", e.Position, e.Count)
+}
+
+// EmptySymbolError is returned when there is nothing between the space of a block and its closing bracket.
+type EmptySymbolError struct {
+	Position
+}
+
+func (e *EmptySymbolError) Error() string {
+	return fmt.Sprintf("%s: expected symbol after count", e.Position)
+}
+
+// shift moves a position found in one line of a larger text to where that line starts in the whole text.
+func (p *Position) shift(offset, lines int) {
+	p.Offset += offset
+	p.Line += lines
+}
@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Stats tells how long the art and its encoding were, in characters.
+type Stats struct {
+	Art     int
+	Encoded int
+}
+
+// Ratio is how many times shorter the encoding is than the art, 2 means it takes half the space.
+func (s Stats) Ratio() float64 {
+	if s.Encoded == 0 {
+		return 1
+	}
+	return float64(s.Art) / float64(s.Encoded)
+}
+
+// EncodeLines encodes the art read from input one line at a time and writes it to output, so the art is never held in
+// memory as a whole. Blank lines and the line endings, \n or \r\n, are kept as they are. With Options.AcrossLines the
+// whole input is read first and encoded as one text.
+func EncodeLines(input io.Reader, output io.Writer, options Options) (Stats, error) {
+	var stats Stats
+	if options.AcrossLines {
+		art, err := io.ReadAll(input)
+		if err != nil {
+			return stats, err
+		}
+		encoded := Encode(string(art), options)
+		stats = Stats{Art: Length(string(art)), Encoded: Length(encoded)}
+		_, err = io.WriteString(output, encoded)
+		return stats, err
+	}
+
+	reader := bufio.NewReader(input)
+	writer := bufio.NewWriter(output)
+	for {
+		line, ending, err := readLine(reader)
+		if err != nil {
+			return stats, err
+		}
+		if line == "" && ending == "" {
+			break
+		}
+		encoded := Encode(line, options)
+		stats.Art += Length(line + ending)
+		stats.Encoded += Length(encoded + ending)
+		if _, err := writer.WriteString(encoded + ending); err != nil {
+			return stats, err
+		}
+	}
+	return stats, writer.Flush()
+}
+
+// DecodeLines decodes the text read from input one line at a time and writes the art to output. A block therefore
+// cannot span lines, the Position of an error is still counted from the start of the input. Whatever was decoded
+// before the error has already been written.
+func DecodeLines(input io.Reader, output io.Writer) error {
+	reader := bufio.NewReader(input)
+	writer := bufio.NewWriter(output)
+	offset, number := 0, 0
+	for {
+		line, ending, err := readLine(reader)
+		if err != nil {
+			return err
+		}
+		if line == "" && ending == "" {
+			break
+		}
+		art, err := Decode(line)
+		if err != nil {
+			if moved, ok := err.(interface{ shift(offset, lines int) }); ok {
+				moved.shift(offset, number)
+			}
+			writer.Flush()
+			return err
+		}
+		if _, err := writer.WriteString(art + ending); err != nil {
+			return err
+		}
+		offset += len(line) + len(ending)
+		number++
+	}
+	return writer.Flush()
+}
+
+// readLine reads the next line and returns it apart from its line ending, which is empty for a last line without one.
+// Both are empty once the input has ended.
+func readLine(reader *bufio.Reader) (string, string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return line[:len(line)-2], "\r\n", nil
+	case strings.HasSuffix(line, "\n"):
+		return line[:len(line)-1], "\n", nil
+	}
+	return line, "", nil
+}
+
+// encodeLines encodes every line of the text on its own, so no block takes in a line break.
+func encodeLines(art string, options Options) string {
+	lines := strings.Split(art, "\n")
+	for i, line := range lines {
+		if trimmed, ok := strings.CutSuffix(line, "\r"); ok {
+			lines[i] = encodeText(trimmed, options) + "\r"
+		} else {
+			lines[i] = encodeText(line, options)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
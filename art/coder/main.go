@@ -1,244 +1,143 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"itinerery/codec"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
-	"unicode"
 )
 
-func slicer(wholeText string) []string {
-	var cashe string
-	var slicedText []string
-	var result []string
-
-	for i := 0; i < len(wholeText); i++ {
-		if string(wholeText[i]) == "[" {
-			slicedText = append(slicedText, cashe)
-			cashe = ""
-			cashe += string(wholeText[i])
-		}
-		if string(wholeText[i]) == "]" {
-			cashe += string(wholeText[i])
-			slicedText = append(slicedText, cashe)
-			cashe = ""
-		}
-		if string(wholeText[i]) != "[" && string(wholeText[i]) != "]" {
-			cashe += string(wholeText[i])
-			if i+1 == len(wholeText) {
-				slicedText = append(slicedText, cashe)
-			}
+// input opens where the art is read from: the file of the -in flag, standard input with -multi or otherwise the
+// first argument.
+func input(path string, multiLine bool) (io.Reader, func() error, error) {
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
 		}
+		return file, file.Close, nil
 	}
-
-	for i := 0; i < len(slicedText); i++ {
-		if string(slicedText[i]) != "" {
-			result = append(result, slicedText[i])
-		}
+	if multiLine {
+		return os.Stdin, func() error { return nil }, nil
 	}
-	return result
+	// The argument gets a line break so the output ends with one, the same as a line printed with fmt.Println.
+	return strings.NewReader(flag.Arg(0) + "\n"), func() error { return nil }, nil
 }
 
-func getNumbers(encoding string) (int, int, error) {
-	var number string
-
-	for i := 1; i < len(encoding)-1; i++ {
-		number = number + string(encoding[i])
-		if !unicode.IsDigit(rune(encoding[i+1])) {
-			break
-		}
+// output creates the file of the -out flag or writes to standard output.
+func output(path string) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
 	}
-
-	lenght := len(number)
-	multiplyer, err := strconv.Atoi(number)
-	return multiplyer, lenght, err
-}
-
-func capture(encodedText []string) (string, error) {
-	pattern := regexp.MustCompile(`^\[.*?\]$`)
-
-	for i := 0; i < len(encodedText); i++ {
-		if match := pattern.FindStringSubmatch(encodedText[i]); match != nil {
-			numberTimes, numberLenght, err := getNumbers(encodedText[i])
-			if err != nil {
-				return "", err
-			}
-			if encodedText[i][numberLenght+1] != ' ' {
-				return "", errors.New("no space")
-			}
-			symbol := encodedText[i][numberLenght+2 : len(encodedText[i])-1]
-			if strings.ContainsAny(symbol, "[]") {
-				return "", errors.New("no symbol")
-			}
-			newMatch := strings.Repeat(symbol, numberTimes)
-			encodedText[i] = newMatch
-		}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
 	}
-	dencodedText := strings.Join(encodedText, "")
-	return dencodedText, nil
+	return file, file.Close, nil
 }
 
-func input() string {
-	var slicedText []string
-	var result []string
-	reader := bufio.NewReader(os.Stdin)
+// The exit codes of the decoder, every kind of malformed art has its own.
+const (
+	exitUnbalancedBracket = 2
+	exitMissingSpace      = 3
+	exitBadCount          = 4
+	exitEmptySymbol       = 5
+)
 
-	for {
-		line, _ := reader.ReadString('\n')
-		slicedText = append(slicedText, line)
-		if strings.Count(strings.Join(slicedText, ""), "\n\n") == 1 {
-			break
-		}
-	}
-	for i := 0; i < len(slicedText); i++ {
-		if string(slicedText[i]) != "" {
-			result = append(result, slicedText[i])
-		}
-	}
-	encodedText := strings.Join(result, "")
-	for {
-		if encodedText[len(encodedText)-1] == '\n' {
-			encodedText = encodedText[:len(encodedText)-1]
-		} else {
-			break
-		}
+// exitCode tells which exit code a decoding error ends the program with.
+func exitCode(err error) int {
+	var unbalanced *codec.UnbalancedBracketError
+	var space *codec.MissingSpaceError
+	var count *codec.BadCountError
+	var symbol *codec.EmptySymbolError
+
+	switch {
+	case errors.As(err, &unbalanced):
+		return exitUnbalancedBracket
+	case errors.As(err, &space):
+		return exitMissingSpace
+	case errors.As(err, &count):
+		return exitBadCount
+	case errors.As(err, &symbol):
+		return exitEmptySymbol
 	}
-	return encodedText
+	return 1
 }
 
-func validity(inputStream string) error {
-	var open int
-	var closed int
+func main() {
+	var multiLine bool
+	var encoder bool
+	var ratio bool
+	var inPath, outPath string
+	var options codec.Options
 
-	for i := 0; i < len(inputStream); i++ {
-		if inputStream[i] == '[' {
-			open++
-		} else if inputStream[i] == ']' {
-			closed++
-		}
+	flag.BoolVar(&multiLine, "multi", false, "Multiline art from standard input")
+	flag.BoolVar(&encoder, "encode", false, "Encoder")
+	flag.IntVar(&options.MaxUnit, "max", codec.DefaultMaxUnit, "Longest repeating unit the encoder looks for")
+	flag.BoolVar(&ratio, "ratio", false, "Print the compression ratio to stderr")
+	flag.StringVar(&inPath, "in", "", "File to read the art from")
+	flag.StringVar(&outPath, "out", "", "File to write the result to")
+	flag.BoolVar(&options.AcrossLines, "across", false, "Let blocks span line breaks")
+	flag.Parse()
+
+	in, closeIn, err := input(inPath, multiLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	if open == closed {
-		return nil
-	} else {
-		return errors.New("unmatched brackets")
+	defer closeIn()
+	out, closeOut, err := output(outPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-}
 
-func numberSymbol(input string) string {
-	number := len(input)
-	symbol := input[0]
-	return "[" + strconv.Itoa(number) + " " + string(symbol) + "]"
-}
-func doubleSymbol(input string) string {
-	number := len(input) / 2
-	symbol := input[0:2]
-	return "[" + strconv.Itoa(number) + " " + string(symbol) + "]"
-}
-
-func reEncoderSingle(decodedText string) string {
-	var cache string
-	var sliced []string
-
-	for i := 0; i < len(decodedText); i++ {
-		if len(cache) == 0 || cache[0] == decodedText[i] {
-			cache = cache + string(decodedText[i])
-		} else if cache[0] != decodedText[i] && len(cache) > 0 {
-			if len(cache) > 1 {
-				sliced = append(sliced, numberSymbol(cache))
-				cache = ""
-				cache = cache + string(decodedText[i])
-			} else if len(cache) == 1 {
-				sliced = append(sliced, cache)
-				cache = ""
-				cache = cache + string(decodedText[i])
-			}
+	if encoder {
+		stats, err := codec.EncodeLines(in, out, options)
+		if err == nil {
+			err = closeOut()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		if i == len(decodedText)-1 && cache[0] == decodedText[i] && len(cache) > 1 {
-			sliced = append(sliced, numberSymbol(cache))
-		} else if i == len(decodedText)-1 {
-			sliced = append(sliced, cache)
+		if ratio {
+			fmt.Fprintf(os.Stderr, "compression ratio %.2f (%d to %d characters)\n", stats.Ratio(), stats.Art, stats.Encoded)
 		}
+		return
 	}
-	result := strings.Join(sliced, "")
-	return result
-}
 
-func reEncoderDouble(decodedText string) string {
-	var cache string
-	var sliced []string
-
-	for j := 0; j <= len(decodedText); j += 2 {
-		if len(decodedText)%2 == 0 {
-			if j == len(decodedText) {
-				if len(cache) > 2 {
-					cache = doubleSymbol(cache)
-				}
-				sliced = append(sliced, cache)
-				break
-			}
+	if options.AcrossLines {
+		err = decodeAll(in, out)
+	} else {
+		err = codec.DecodeLines(in, out)
+	}
+	if closeErr := closeOut(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if inPath != "" {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", inPath, err)
 		} else {
-			if j+1 == len(decodedText) {
-				if len(cache) > 2 {
-					cache = doubleSymbol(cache)
-				}
-				cache += string(decodedText[j])
-				sliced = append(sliced, cache)
-				break
-			}
-		}
-		if len(cache) < 2 {
-			cache += string(decodedText[j]) + string(decodedText[j+1])
-		} else if (string(cache[0]) + string(cache[1])) == (string(decodedText[j]) + string(decodedText[j+1])) {
-			cache += string(decodedText[j]) + string(decodedText[j+1])
-		} else if (string(cache[0])+string(cache[1])) != (string(decodedText[j])+string(decodedText[j+1])) && len(cache) > 0 {
-			if len(cache) > 2 {
-				sliced = append(sliced, doubleSymbol(cache))
-				cache = string(decodedText[j]) + string(decodedText[j+1])
-			} else if len(cache) == 2 {
-				sliced = append(sliced, cache)
-				cache = string(decodedText[j]) + string(decodedText[j+1])
-			}
+			fmt.Fprintln(os.Stderr, err)
 		}
+		os.Exit(exitCode(err))
 	}
-	result := strings.Join(sliced, "")
-	return result
 }
 
-func main() {
-	var encodedText string
-	var multiLine bool
-	var encoder bool
-
-	flag.BoolVar(&multiLine, "multi", false, "Multiline art")
-	flag.BoolVar(&encoder, "encode", false, "Encoder")
-	flag.Parse()
-
-	if multiLine {
-		encodedText = input()
-	} else {
-		encodedText = flag.Arg(0)
+// decodeAll reads the whole input before decoding it, so a block can span line breaks.
+func decodeAll(in io.Reader, out io.Writer) error {
+	encoded, err := io.ReadAll(in)
+	if err != nil {
+		return err
 	}
-	if encoder {
-
-		reencodedText := reEncoderDouble(reEncoderSingle(encodedText))
-		fmt.Println(reencodedText)
-	} else {
-		err := validity(encodedText)
-		if err != nil {
-			fmt.Println("Error")
-			return
-		}
-		slicedText := slicer(encodedText)
-		decodedText, err := capture(slicedText)
-		if err != nil {
-			fmt.Println("Error")
-			return
-		}
-		fmt.Println(decodedText)
+	art, err := codec.Decode(string(encoded))
+	if err != nil {
+		return err
 	}
+	_, err = io.WriteString(out, art)
+	return err
 }
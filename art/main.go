@@ -2,12 +2,9 @@ package main
 
 import (
 	"html/template"
+	"itinerery/codec"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
 )
 
 type Input struct {
@@ -25,25 +22,23 @@ func HandleFunc(w http.ResponseWriter, r *http.Request) {
 
 func Decoder(w http.ResponseWriter, r *http.Request) {
 	log.Println("POST Input Data...")
-	var cmd *exec.Cmd
 
 	inputStructure := Input{
 		userInput: r.FormValue("inputText"),
 		encoding:  r.Form.Has("Encode"),
 	}
-	cwd, err := os.Getwd()
 
-	if inputStructure.encoding { // This checks if the user wants to encode or decode, the lines are different because the command function does workj with an empty variable.
-		cmd = exec.Command("go", "run", filepath.Join(cwd, "coder/main.go"), "-encode", inputStructure.userInput)
-	} else {
-		cmd = exec.Command("go", "run", filepath.Join(cwd, "coder/main.go"), inputStructure.userInput)
+	if inputStructure.encoding { // The codec package is used directly, so the art no longer goes through the command line tool.
+		log.Println("Get data for printing...")
+		w.WriteHeader(http.StatusAccepted)
+		temp.Execute(w, codec.Encode(inputStructure.userInput, codec.Options{}))
+		return
 	}
-	cmd.Dir = cwd
-	output, err := cmd.CombinedOutput()
-	art := string(output) // Output is written in bytes so it has to be converted into strings.
-	if err != nil || strings.Contains(art, "Error") {
+
+	art, err := codec.Decode(inputStructure.userInput)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		temp.Execute(w, err)
+		temp.Execute(w, "Error: "+err.Error())
 	} else {
 		log.Println("Get data for printing...")
 		w.WriteHeader(http.StatusAccepted)
@@ -52,6 +47,7 @@ func Decoder(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	temp = template.Must(template.ParseFiles("design.html")) // The decoder can be posted to before the page was loaded.
 	address := "localhost:4444"
 	mux := http.NewServeMux()
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(".")))) //Made a static path so that the server could read the css file from this folder and not from a link.
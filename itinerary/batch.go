@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"itinerary/prettifier"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type batchResult struct {
+	input  string
+	report prettifier.Report
+	err    error
+}
+
+// isBatch tells whether the input is a directory or a glob pattern rather than a single itinerary.
+func isBatch(route string) bool {
+	info, err := os.Stat(route)
+	if err == nil {
+		return info.IsDir()
+	}
+	return strings.ContainsAny(route, "*?[")
+}
+
+// batchFiles lists every regular file in a directory or every regular file that matches a glob pattern.
+func batchFiles(pattern string) ([]string, error) {
+	info, err := os.Stat(pattern)
+	if err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "*")
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err == nil && info.Mode().IsRegular() {
+			files = append(files, match)
+		}
+	}
+	return files, nil
+}
+
+// batchOutputs gives the output path of every input. Inputs that would write the same output, like trip.txt and trip.md
+// or trip.txt in two directories, get an error instead of a path. The check is made before any file is written, so no
+// worker writes over the result of another.
+func batchOutputs(inputs []string, outputDir, extension string) ([]string, []error) {
+	outputs := make([]string, len(inputs))
+	errs := make([]error, len(inputs))
+	writers := make(map[string][]int)
+
+	for i, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input)) + extension
+		outputs[i] = filepath.Join(outputDir, name)
+		outputAbs, _ := filepath.Abs(outputs[i])
+		writers[outputAbs] = append(writers[outputAbs], i)
+	}
+
+	for _, indexes := range writers {
+		if len(indexes) < 2 {
+			continue
+		}
+		for _, i := range indexes {
+			var others []string
+			for _, j := range indexes {
+				if j != i {
+					others = append(others, inputs[j])
+				}
+			}
+			errs[i] = fmt.Errorf("output %s would also be written by %s", outputs[i], strings.Join(others, ", "))
+		}
+	}
+	return outputs, errs
+}
+
+// runBatch prettifies every input into the output directory with a fixed number of workers. The workers share the one
+// Prettifier and so the one airport index. With calendar set every output gets its calendar next to it. The results are
+// in the same order as the inputs, an input whose output collides with another gets its error from batchOutputs and is
+// not prettified.
+func runBatch(converter *prettifier.Prettifier, inputs []string, outputDir, extension string, workers int, calendar bool) []batchResult {
+	results := make([]batchResult, len(inputs))
+	outputs, errs := batchOutputs(inputs, outputDir, extension)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				report, err := prettifyFile(converter, inputs[i], outputs[i], calendar)
+				results[i] = batchResult{input: inputs[i], report: report, err: err}
+			}
+		}()
+	}
+
+	for i := range inputs {
+		if errs[i] != nil {
+			results[i] = batchResult{input: inputs[i], err: errs[i]}
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func prettifyFile(converter *prettifier.Prettifier, inputPath, outputPath string, calendar bool) (prettifier.Report, error) {
+	inputAbs, _ := filepath.Abs(inputPath)
+	outputAbs, _ := filepath.Abs(outputPath)
+	calendarAbs, _ := filepath.Abs(calendarPath(outputPath))
+	if inputAbs == outputAbs || (calendar && inputAbs == calendarAbs) {
+		return prettifier.Report{}, errors.New("output would overwrite the input")
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return prettifier.Report{}, err
+	}
+	defer input.Close()
+
+	output, err := os.Create(outputPath)
+	if err != nil {
+		return prettifier.Report{}, err
+	}
+
+	report, err := converter.PrettifyReport(input, output)
+	closeErr := output.Close()
+	if err != nil {
+		os.Remove(outputPath)
+		return report, err
+	}
+	if calendar && closeErr == nil {
+		return report, writeCalendar(outputPath, report.Events)
+	}
+	return report, closeErr
+}
+
+// batchSummary prints the warnings of every file to stderr and the totals to stdout. It returns the number of failed files.
+func batchSummary(results []batchResult) int {
+	resolved := 0
+	failures := 0
+
+	for _, result := range results {
+		resolved += result.report.Resolved
+		if result.err != nil {
+			failures++
+			printError(result.input + ": " + result.err.Error())
+			continue
+		}
+		for _, diagnostic := range result.report.Diagnostics {
+			fmt.Fprintln(os.Stderr, result.input+": warning: "+diagnostic.String())
+		}
+	}
+
+	fmt.Printf("Files processed: %d\nTokens resolved: %d\nFailures: %d\n", len(results), resolved, failures)
+	return failures
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"itinerary/prettifier"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	lookup := "name,iso_country,municipality,icao_code,iata_code,coordinates\n" +
+		"Helsinki Vantaa Airport,FI,Helsinki,EFHK,HEL,\"24.96, 60.31\"\n"
+	airports, err := prettifier.LoadAirports(strings.NewReader(lookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	files := map[string]string{
+		"trip.txt":     "From #HEL",
+		"trip.md":      "To #HEL",
+		"other.txt":    "Via #HEL and #XYZ",
+		"notes/a.text": "not an itinerary",
+	}
+	for name, content := range files {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !isBatch(inputDir) || !isBatch(filepath.Join(inputDir, "*.txt")) || isBatch(filepath.Join(inputDir, "trip.txt")) {
+		t.Error("isBatch should hold for the directory and the glob but not for a single file")
+	}
+
+	inputs, err := batchFiles(inputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(inputDir, "other.txt"), filepath.Join(inputDir, "trip.md"), filepath.Join(inputDir, "trip.txt")}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Fatalf("batchFiles = %v, want %v", inputs, want)
+	}
+	if globbed, err := batchFiles(filepath.Join(inputDir, "*.txt")); err != nil || len(globbed) != 2 {
+		t.Errorf("batchFiles of the glob = %v, %v, want the two txt files", globbed, err)
+	}
+
+	results := runBatch(prettifier.New(airports, prettifier.Options{}), inputs, outputDir, ".txt", 4, false)
+	if results[0].err != nil || results[0].report.Resolved != 1 || len(results[0].report.Diagnostics) != 1 {
+		t.Errorf("other.txt = %+v, want one resolved and one unknown code", results[0])
+	}
+	// trip.txt and trip.md would both write trip.txt, so neither is written.
+	for _, result := range results[1:] {
+		if result.err == nil || !strings.Contains(result.err.Error(), "would also be written by") {
+			t.Errorf("%s: err = %v, want a collision", result.input, result.err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "trip.txt")); !os.IsNotExist(err) {
+		t.Errorf("trip.txt was written despite the collision: %v", err)
+	}
+	if output, err := os.ReadFile(filepath.Join(outputDir, "other.txt")); err != nil || string(output) != "Via Helsinki Vantaa Airport and #XYZ" {
+		t.Errorf("other.txt = %q, %v", output, err)
+	}
+
+	// Files with the same name in different directories, matched by one glob, collide as well.
+	nested := filepath.Join(inputDir, "notes", "trip.txt")
+	if err := os.WriteFile(nested, []byte("#HEL"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	results = runBatch(prettifier.New(airports, prettifier.Options{}), []string{inputs[2], nested}, outputDir, ".html", 2, false)
+	for _, result := range results {
+		if result.err == nil {
+			t.Errorf("%s: want a collision on trip.html", result.input)
+		}
+	}
+
+	if failures := batchSummary(results); failures != 2 {
+		t.Errorf("batchSummary = %d failures, want 2", failures)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"itinerary/prettifier"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// calendarPath gives the path of the calendar that is written next to an output, trip.html gets trip.ics.
+func calendarPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".ics"
+}
+
+// writeCalendar writes the events of a prettified itinerary into the calendar next to its output.
+func writeCalendar(outputPath string, events []prettifier.Event) error {
+	route := calendarPath(outputPath)
+	if route == outputPath {
+		return errors.New("calendar would overwrite the output")
+	}
+
+	file, err := os.Create(route)
+	if err != nil {
+		return err
+	}
+	err = prettifier.WriteCalendar(file, events)
+	closeErr := file.Close()
+	if err != nil {
+		os.Remove(route)
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,3 @@
+module itinerary
+
+go 1.21.5
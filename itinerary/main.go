@@ -1,290 +1,293 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
+	"bytes"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"itinerary/prettifier"
 	"os"
 	"regexp"
-	"strconv"
-	"strings"
-	"time"
+	"runtime"
+	_ "time/tzdata" // Airport timezones work even where the system has no zoneinfo.
 )
 
-func inputRead(route string) []string {
-	rawFile, _ := os.ReadFile(route)
-	stringFile := string(rawFile)
-
-	expression := regexp.MustCompile(` {2,}`)
-	processedFile := expression.ReplaceAllString(stringFile, " ")
-
-	expression = regexp.MustCompile(`[\v\f\r]+`)
-	processedFile = expression.ReplaceAllString(processedFile, "\n")
-
-	expression = regexp.MustCompile(`\n{3,}`)
-	processedFile = expression.ReplaceAllString(processedFile, "\n\n")
-
-	lines := strings.Split(processedFile, "\n")
+// printError writes a message in red to stderr, so it never ends up in output that is piped to another program.
+func printError(message string) {
+	fmt.Fprintln(os.Stderr, "\033[31m"+message+"\033[0m")
+}
 
-	if len(lines) > 0 && lines[len(lines)-1] == "" {
-		lines = lines[:len(lines)-1]
+func Validation(inRoute string, airRoutes []string, verbose bool) (*prettifier.Airports, error) {
+	if inRoute != "-" {
+		_, err := os.Stat(inRoute)
+		if err != nil {
+			printError("Input not found.")
+			return nil, err
+		}
 	}
-
-	return lines
+	return lookupValidation(airRoutes, verbose)
 }
 
-func airportRead(route string, condition string) string {
-	var unchanged string
-	city := false
-
-	if condition[0] == '*' {
-		city = true
-		condition = condition[1:]
-		unchanged = unchanged + "*"
+// lookupValidation loads and merges the airport lookups and prints why they could not be used. In verbose mode every
+// code that the lookups disagree on is printed to stderr.
+func lookupValidation(airRoutes []string, verbose bool) (*prettifier.Airports, error) {
+	airports, conflicts, err := prettifier.LoadAirportsFiles(airRoutes...)
+	if errors.Is(err, os.ErrNotExist) {
+		printError("Airport lookup not found.")
+		return nil, err
 	}
 
-	if condition[0:2] == "##" {
-		if len(condition) < 6 {
-			return condition
-		}
-		condition = condition[2:6]
-		unchanged = unchanged + "##"
-	} else if condition[0:1] == "#" {
-		condition = condition[1:4]
-		unchanged = unchanged + "#"
+	var missing *prettifier.MissingColumnError
+	var empty *prettifier.EmptyCellError
+	if errors.As(err, &missing) || errors.As(err, &empty) {
+		printError("Airport lookup malformed, " + err.Error() + ".")
+		return nil, err
+	}
+	if err != nil {
+		printError("Airport lookup malformed.")
+		return nil, err
 	}
 
-	unchanged = unchanged + condition
-	file, _ := os.Open(route)
-
-	defer file.Close()
-	reader := csv.NewReader(bufio.NewReader(file))
-	headers, _ := reader.Read()
-	data := make(map[string]string)
-
-	for {
-		record, err := reader.Read()
-
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-		}
-
-		for i := 0; i < len(headers) && i < len(record); i++ {
-			data[headers[i]] = record[i]
-
-			if strings.Contains(record[i], condition) && len(record[i]) == len(condition) {
-				airName := data["name"]
-				if city {
-					airName = data["municipality"]
-				}
-				return airName
-			}
+	if verbose {
+		for _, conflict := range conflicts {
+			fmt.Fprintln(os.Stderr, "lookup conflict: "+conflict.String())
 		}
 	}
-	return unchanged
+	return airports, nil
 }
 
-func formatTime(raw string) (string, error) {
-	var formated string
-
-	dPattern := regexp.MustCompile(`^D\((\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(Z|[-+]\d{2}:\d{2}))\)[.,!?]?`)
-	tPattern := regexp.MustCompile(`^(T12|T24)\((\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(Z|[-+]\d{2}:\d{2}))\)[.,!?]?`)
+func outputDisplay(text string) {
+	offsetPattern := regexp.MustCompile(`[\+\-]\d{2}:\d{2}`)
+	matches := offsetPattern.FindAllString(text, -1)
 
-	if match := tPattern.FindStringSubmatch(raw); match == nil {
-		if match := dPattern.FindStringSubmatch(raw); match == nil {
-			return raw, errors.New(" ")
-		}
+	for _, match := range matches {
+		escapedMatch := "\033[1m" + match + "\033[0m"
+		quotedMatch := regexp.QuoteMeta(match)
+		text = regexp.MustCompile(quotedMatch).ReplaceAllString(text, escapedMatch)
 	}
 
-	if raw[0:2] == "D(" {
-		firstSplit := strings.Split(raw, "T")
-		secondSplit := strings.Split(firstSplit[0], "-")
-		splitDay := secondSplit[2]
-		splitYear := secondSplit[0][2:]
-		numbMonth, _ := strconv.Atoi(secondSplit[1])
+	fmt.Println(text)
+}
 
-		monthTime := time.Date(0, time.Month(numbMonth), 1, 0, 0, 0, 0, time.UTC)
-		month := monthTime.Format("Jan")
-		formated = splitDay + " " + month + " " + splitYear
+func main() {
+	var helpFlag bool
+	var displayFlag bool
+	var localeFlag string
+	var zonesFlag bool
+	var formatFlag string
+	var strictFlag bool
+	var workersFlag int
+	var addressFlag string
+	var legsFlag bool
+	var airportFlag string
+	var cityFlag string
+	var correctFlag bool
+	var encodeFlag bool
+	var whitespaceFlag string
+	var spacesFlag bool
+	var crlfFlag bool
+	var blankLinesFlag int
+	var verboseFlag bool
+	var calendarFlag bool
+	var citiesFlag bool
 
-	} else {
-		var times []string
-		var currentTime string
-		var movement string
+	flag.BoolVar(&helpFlag, "h", false, "Display usage.")
+	flag.BoolVar(&displayFlag, "d", false, "Display the output.")
+	flag.StringVar(&localeFlag, "locale", "en", "Language of dates and times: en, et, de or fi.")
+	flag.StringVar(&formatFlag, "format", "text", "Output format: text, html, json or markdown.")
+	flag.BoolVar(&strictFlag, "strict", false, "Fail with a report when an airport code or date cannot be resolved.")
+	flag.IntVar(&workersFlag, "workers", runtime.NumCPU(), "Files prettified at the same time in batch mode.")
+	flag.StringVar(&addressFlag, "addr", "localhost:7777", "Address of the server in serve mode.")
+	flag.BoolVar(&legsFlag, "legs", false, "Add a summary of the flight legs and their distances.")
+	flag.StringVar(&airportFlag, "airport", "{name}", "Template of #IATA and ##ICAO codes, for example \"{name} ({iata}), {country}\".")
+	flag.StringVar(&cityFlag, "city", "{city}", "Template of *# codes.")
+	flag.BoolVar(&correctFlag, "correct", false, "Replace a mistyped airport code when there is only one suggestion for it or only its case is wrong.")
+	flag.BoolVar(&encodeFlag, "encode", false, "Turn a prettified itinerary back into airport codes and date and time tokens.")
+	flag.BoolVar(&citiesFlag, "cities", false, "Also encode municipalities as *# codes in encode mode.")
+	flag.StringVar(&whitespaceFlag, "whitespace", "classic", "Whitespace rules of the input: classic, compact or preserve.")
+	flag.BoolVar(&spacesFlag, "spaces", false, "Keep runs of spaces, overrides the whitespace profile.")
+	flag.BoolVar(&crlfFlag, "crlf", false, "Read \\r\\n as one line ending, overrides the whitespace profile.")
+	flag.IntVar(&blankLinesFlag, "blank-lines", 1, "Most blank lines in a row, -1 for no limit, overrides the whitespace profile.")
+	flag.BoolVar(&verboseFlag, "v", false, "Report the airport codes that the lookups disagree on.")
+	flag.BoolVar(&calendarFlag, "ics", false, "Also write an iCalendar file of the lines with a time and an airport next to the output.")
+	flag.BoolVar(&zonesFlag, "zones", false, "Write timezone names instead of offsets, needs a timezone column in the lookup.")
+	flag.Parse()
 
-		add := false
-		neg := false
-		direction := "+"
-		dateTime := strings.Split(raw, "T")
+	serveMode := flag.Arg(0) == "serve" && flag.NArg() >= 2
+	if helpFlag || (flag.NArg() < 3 && !serveMode) {
+		fmt.Println("Usage:\n go run . [-format text] [-locale en] [-zones] [-whitespace classic] [-spaces] [-crlf] [-blank-lines 1] [-strict] [-correct] [-legs] [-airport template] [-city template] [-ics] [-v] input.txt output.txt airport-lookup.csv [overrides.csv ...]")
+		fmt.Println(" Use - as the input or output to read from stdin or write to stdout.")
+		fmt.Println(" Use -encode to turn airport names, municipalities, dates and times written in the locale back into codes and tokens.")
+		fmt.Println(" Use a directory or a glob pattern as the input and a directory as the output to prettify many files.")
+		fmt.Println(" Later lookups, or a directory of them, override the codes of earlier ones.")
+		fmt.Println(" go run . [-addr localhost:7777] serve airport-lookup.csv [overrides.csv ...]")
+		return
+	}
 
-		if raw[0:4] == "T12(" {
-			add = true
-		}
+	locale, err := prettifier.LookupLocale(localeFlag)
+	if err != nil {
+		printError("Locale not supported, " + err.Error() + ".")
+		return
+	}
 
-		if strings.Contains(dateTime[2], "+") {
-			times = strings.Split(dateTime[2], "+")
-			currentTime = times[0][:5]
-			movement = times[1][:5]
-		} else if strings.Contains(dateTime[2], "-") {
-			times = strings.Split(dateTime[2], "-")
-			currentTime = times[0][:5]
-			movement = times[1][:5]
-			neg = true
-		} else {
-			currentTime = dateTime[2][0:5]
-			movement = "00:00"
-		}
+	format, err := prettifier.ParseFormat(formatFlag)
+	if err != nil {
+		printError("Format not supported, " + err.Error() + ".")
+		return
+	}
 
-		if neg {
-			direction = "-"
+	whitespace, err := prettifier.LookupWhitespace(whitespaceFlag)
+	if err != nil {
+		printError("Whitespace rules not supported, " + err.Error() + ".")
+		return
+	}
+	// The flags of single rules override the profile, but only the ones that were given.
+	rules := *whitespace
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "spaces":
+			rules.CollapseSpaces = !spacesFlag
+		case "crlf":
+			rules.CRLF = crlfFlag
+		case "blank-lines":
+			rules.MaxBlankLines = blankLinesFlag
 		}
-		hour, _ := strconv.Atoi(currentTime[0:2])
+	})
+	whitespace = &rules
 
-		if add {
-			affix := "AM ("
-			if hour > 12 {
-				newHour := strconv.Itoa(hour - 12)
-				currentTime = newHour + currentTime[2:]
-				affix = "PM ("
-			}
-			formated = currentTime + affix + direction + movement + ")"
-		} else {
-			formated = currentTime + " (" + direction + movement + ")"
+	for _, template := range []string{airportFlag, cityFlag} {
+		if err := prettifier.CheckTemplate(template); err != nil {
+			printError("Template not supported, " + err.Error() + ".")
+			return
 		}
 	}
-	return formated, nil
-}
-
-func outputWrite(route, processed string) {
-
-	file, _ := os.Create(route)
-	defer file.Close()
-	_, _ = file.WriteString(processed)
 
-}
-
-func processText(raw []string, airportPath string) string {
-	var processed []string
-	for i := 0; i < len(raw); i++ {
-		parts := strings.Split(raw[i], " ")
-
-		for i := 0; i < len(parts); i++ {
-			var punct string
-			re := regexp.MustCompile(`^(D\(.*?|T12\(.*?|T24\(.*?)`)
+	options := prettifier.Options{
+		Locale:          locale,
+		ZoneNames:       zonesFlag,
+		Format:          format,
+		Strict:          strictFlag,
+		Legs:            legsFlag,
+		AirportTemplate: airportFlag,
+		CityTemplate:    cityFlag,
+		AutoCorrect:     correctFlag,
+		EncodeCities:    citiesFlag,
+		Whitespace:      whitespace,
+	}
 
-			if match := re.FindStringSubmatch(parts[i]); match != nil {
-				if strings.ContainsAny(parts[i][len(parts[i])-1:], ",.!?") {
-					punct = parts[i][len(parts[i])-1:]
-				}
-				newTime, err := formatTime(parts[i])
-				if err != nil {
-					continue
-				}
-				parts[i] = newTime + punct
-			}
-			if strings.Contains(parts[i], "#") {
-				if strings.ContainsAny(parts[i][len(parts[i])-1:], ",.!?") {
-					punct = parts[i][len(parts[i])-1:]
-				}
-				airportName := airportRead(airportPath, parts[i])
-				parts[i] = airportName + punct
-			}
+	if serveMode {
+		airports, err := lookupValidation(flag.Args()[1:], verboseFlag)
+		if err != nil {
+			return
 		}
-		raw[i] = strings.Join(parts, " ")
-		processed = append(processed, raw[i])
+		serve(addressFlag, airports, options)
+		return
 	}
 
-	finalizing := strings.Join(processed, "\n")
-	return finalizing
-}
+	inputPath := flag.Arg(0)
+	outputPath := flag.Arg(1)
+	airportPaths := flag.Args()[2:]
 
-func Validation(inRoute, airRoute string) error {
-	red := "\033[31m"
-	reset := "\033[0m"
-	_, err := os.ReadFile(inRoute)
-	if err != nil {
-		fmt.Println(red + "Input not found." + reset)
-		return err
+	var batchInputs []string
+	if isBatch(inputPath) {
+		batchInputs, err = batchFiles(inputPath)
+		if err != nil || len(batchInputs) == 0 {
+			printError("No input files found.")
+			return
+		}
+		inputPath = batchInputs[0]
 	}
 
-	airports, err := os.Open(airRoute)
+	airports, err := Validation(inputPath, airportPaths, verboseFlag)
 	if err != nil {
-		fmt.Println(red + "Airport lookup not found." + reset)
-		return err
+		return
 	}
-	defer airports.Close()
 
-	reader := csv.NewReader(bufio.NewReader(airports))
-	header, _ := reader.Read()
-
-	if len(header) != 6 {
-		fmt.Println(red + "Airport lookup malformed." + reset)
-		return errors.New(" ")
+	if batchInputs != nil && encodeFlag {
+		printError("Encode mode works on a single itinerary.")
+		return
+	}
+	if calendarFlag && (encodeFlag || outputPath == "-") {
+		printError("The calendar is written next to an output file of a prettified itinerary.")
+		return
 	}
 
-	for {
-		row, err := reader.Read()
+	if batchInputs != nil {
+		err = os.MkdirAll(outputPath, 0755)
 		if err != nil {
-			break
+			printError("Output directory could not be created.")
+			return
 		}
-
-		for _, cell := range row {
-			if cell == "" {
-				fmt.Println(red + "Airport lookup malformed." + reset)
-				return errors.New(" ")
-			}
+		results := runBatch(prettifier.New(airports, options), batchInputs, outputPath, format.Extension(), max(workersFlag, 1), calendarFlag)
+		if batchSummary(results) > 0 {
+			os.Exit(1)
 		}
+		return
 	}
-	return nil
-}
 
-func outputDisplay(text string) {
-	offsetPattern := regexp.MustCompile(`[\+\-]\d{2}:\d{2}`)
-	matches := offsetPattern.FindAllString(text, -1)
-
-	for _, match := range matches {
-		escapedMatch := "\033[1m" + match + "\033[0m"
-		quotedMatch := regexp.QuoteMeta(match)
-		text = regexp.MustCompile(quotedMatch).ReplaceAllString(text, escapedMatch)
+	input := os.Stdin
+	if inputPath != "-" {
+		input, err = os.Open(inputPath)
+		if err != nil {
+			printError("Input not found.")
+			return
+		}
+		defer input.Close()
 	}
 
-	fmt.Println(text)
-}
-
-func main() {
-	var helpFlag bool
-	var displayFlag bool
-
-	flag.BoolVar(&helpFlag, "h", false, "Display usage.")
-	flag.BoolVar(&displayFlag, "d", false, "Display the output.")
-	flag.Parse()
+	output := os.Stdout
+	if outputPath != "-" {
+		output, err = os.Create(outputPath)
+		if err != nil {
+			printError("Output could not be written.")
+			return
+		}
+		defer output.Close()
+	}
 
-	if helpFlag || flag.NArg() < 3 {
-		fmt.Println("Usage:\n go run . ./input.txt ./output.txt ./airport-lookup.csv")
-		return
+	if !strictFlag {
+		options.Warnings = os.Stderr
 	}
 
-	inputPath := flag.Arg(0)[2:]
-	outputPath := flag.Arg(1)[2:]
-	airportPath := flag.Arg(2)[2:]
+	// The display needs the whole text, so it is only collected when asked for and not already going to stdout.
+	var converted bytes.Buffer
+	var destination io.Writer = output
+	displayFlag = displayFlag && outputPath != "-"
+	if displayFlag {
+		destination = io.MultiWriter(output, &converted)
+	}
 
-	err := Validation(inputPath, airportPath)
+	var report prettifier.Report
+	if encodeFlag {
+		err = prettifier.New(airports, options).Encode(input, destination)
+	} else {
+		report, err = prettifier.New(airports, options).PrettifyReport(input, destination)
+	}
+	var diagnostics *prettifier.DiagnosticsError
+	if errors.As(err, &diagnostics) {
+		printError(diagnostics.Error())
+		if outputPath != "-" {
+			output.Close()
+			os.Remove(outputPath)
+		}
+		os.Exit(1)
+	}
 	if err != nil {
+		printError("Itinerary could not be prettified, " + err.Error() + ".")
 		return
 	}
 
-	rawText := inputRead(inputPath)
-
-	convertedText := processText(rawText, airportPath)
-
-	outputWrite(outputPath, convertedText)
+	if calendarFlag {
+		if err := writeCalendar(outputPath, report.Events); err != nil {
+			printError("Calendar could not be written, " + err.Error() + ".")
+			return
+		}
+	}
 
-	if displayFlag {
-		outputDisplay(convertedText)
+	if displayFlag && (format == prettifier.FormatText || encodeFlag) {
+		outputDisplay(converted.String())
+	} else if displayFlag {
+		fmt.Println(converted.String())
 	}
 }
@@ -0,0 +1,233 @@
+package prettifier
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// RequiredColumns are the columns an airport lookup has to contain, any other columns are ignored and the order does not matter.
+var RequiredColumns = []string{"name", "iso_country", "municipality", "icao_code", "iata_code", "coordinates"}
+
+// TimezoneColumn is an optional column of the airport lookup holding the IANA zone of the airport, for example Europe/Helsinki.
+const TimezoneColumn = "timezone"
+
+// Airport is a single row of the airport lookup.
+type Airport struct {
+	Name         string
+	Country      string
+	Municipality string
+	ICAO         string
+	IATA         string
+	Coordinates  string
+	Timezone     string
+
+	location   *time.Location
+	latitude   float64
+	longitude  float64
+	positioned bool
+	source     string // Path of the lookup the row came from, empty when it was not read from a file.
+}
+
+// Airports is the airport lookup loaded into memory, indexed by IATA code, ICAO code and municipality.
+type Airports struct {
+	byIATA         map[string]*Airport
+	byICAO         map[string]*Airport
+	byMunicipality map[string][]*Airport
+	all            []*Airport // Every row in the order of the lookup.
+}
+
+// MissingColumnError is returned when the header of the lookup does not name one of the RequiredColumns.
+type MissingColumnError struct {
+	Column string
+}
+
+func (e *MissingColumnError) Error() string {
+	return fmt.Sprintf("missing column %q", e.Column)
+}
+
+// EmptyCellError is returned when a row of the lookup has an empty required cell.
+type EmptyCellError struct {
+	Line   int
+	Column string
+}
+
+func (e *EmptyCellError) Error() string {
+	return fmt.Sprintf("line %d: empty %s cell", e.Line, e.Column)
+}
+
+// LoadAirportsFile opens the csv at route and loads it with LoadAirports.
+func LoadAirportsFile(route string) (*Airports, error) {
+	file, err := os.Open(route)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	airports, err := LoadAirports(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range airports.all {
+		entry.source = route
+	}
+	return airports, nil
+}
+
+// LoadAirports reads an airport lookup csv into an index. The required columns are found by their header names.
+func LoadAirports(source io.Reader) (*Airports, error) {
+	reader := csv.NewReader(bufio.NewReader(source))
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := airportColumns(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	airports := &Airports{
+		byIATA:         make(map[string]*Airport),
+		byICAO:         make(map[string]*Airport),
+		byMunicipality: make(map[string][]*Airport),
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, required := range RequiredColumns {
+			if record[columns[required]] == "" {
+				line, _ := reader.FieldPos(0)
+				return nil, &EmptyCellError{Line: line, Column: required}
+			}
+		}
+
+		entry := &Airport{
+			Name:         record[columns["name"]],
+			Country:      record[columns["iso_country"]],
+			Municipality: record[columns["municipality"]],
+			ICAO:         record[columns["icao_code"]],
+			IATA:         record[columns["iata_code"]],
+			Coordinates:  record[columns["coordinates"]],
+		}
+
+		entry.latitude, entry.longitude, entry.positioned = parseCoordinates(entry.Coordinates)
+
+		if column, ok := columns[TimezoneColumn]; ok && record[column] != "" {
+			entry.Timezone = record[column]
+			entry.location, err = time.LoadLocation(entry.Timezone)
+			if err != nil {
+				line, _ := reader.FieldPos(column)
+				return nil, fmt.Errorf("line %d: unknown timezone %q", line, entry.Timezone)
+			}
+		}
+		airports.add(entry)
+	}
+	return airports, nil
+}
+
+func airportColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+
+	for _, required := range RequiredColumns {
+		if _, ok := columns[required]; !ok {
+			return nil, &MissingColumnError{Column: required}
+		}
+	}
+	return columns, nil
+}
+
+func (a *Airports) add(entry *Airport) {
+	a.all = append(a.all, entry)
+	// The first row wins when a code appears twice, the same as the old top to bottom scan.
+	if _, ok := a.byIATA[entry.IATA]; entry.IATA != "" && !ok {
+		a.byIATA[entry.IATA] = entry
+	}
+	if _, ok := a.byICAO[entry.ICAO]; entry.ICAO != "" && !ok {
+		a.byICAO[entry.ICAO] = entry
+	}
+	if entry.Municipality != "" {
+		a.byMunicipality[entry.Municipality] = append(a.byMunicipality[entry.Municipality], entry)
+	}
+}
+
+// IATA returns the airport with the three letter IATA code.
+func (a *Airports) IATA(code string) (*Airport, bool) {
+	entry, ok := a.byIATA[code]
+	return entry, ok
+}
+
+// ICAO returns the airport with the four letter ICAO code.
+func (a *Airports) ICAO(code string) (*Airport, bool) {
+	entry, ok := a.byICAO[code]
+	return entry, ok
+}
+
+// Municipality returns every airport that serves the municipality.
+func (a *Airports) Municipality(name string) []*Airport {
+	return a.byMunicipality[name]
+}
+
+// lookup finds the airport of a #IATA, ##ICAO or *# word, it returns nil for unknown codes.
+func (a *Airports) lookup(condition string) *Airport {
+	condition = strings.TrimPrefix(condition, "*")
+
+	if strings.HasPrefix(condition, "##") {
+		if len(condition) != 6 {
+			return nil
+		}
+		return a.byICAO[condition[2:6]]
+	}
+	if strings.HasPrefix(condition, "#") && len(condition) == 4 {
+		return a.byIATA[condition[1:4]]
+	}
+	return nil
+}
+
+// airportRead resolves a #IATA, ##ICAO or *# word. It returns the part of the word that held the code and the airport,
+// which is nil when the code is unknown or the word is not exactly as long as a code, so #HELsinki is not #HEL.
+func (a *Airports) airportRead(condition string) (string, *Airport) {
+	var unchanged string
+	var found *Airport
+
+	if strings.HasPrefix(condition, "*") {
+		condition = condition[1:]
+		unchanged = unchanged + "*"
+	}
+
+	if strings.HasPrefix(condition, "##") {
+		if len(condition) != 6 {
+			return unchanged + condition, nil
+		}
+		condition = condition[2:6]
+		unchanged = unchanged + "##"
+		found = a.byICAO[condition]
+	} else if strings.HasPrefix(condition, "#") {
+		if len(condition) != 4 {
+			return unchanged + condition, nil
+		}
+		condition = condition[1:4]
+		unchanged = unchanged + "#"
+		found = a.byIATA[condition]
+	}
+
+	return unchanged + condition, found
+}
@@ -0,0 +1,126 @@
+package prettifier
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsLayout is the UTC date and time form of iCalendar.
+const icsLayout = "20060102T150405Z"
+
+// Event is a line of the itinerary that has both a date or time and an airport, the way it goes into a calendar.
+type Event struct {
+	Line        int
+	Summary     string    // The airports of the line in order, for example "Helsinki Vantaa Airport → Tallinn".
+	Location    string    // The first airport of the line.
+	Description string    // The whole prettified line.
+	Start       time.Time // The first time of the line.
+	End         time.Time // The last time of the line when it is later than the first, otherwise zero.
+	AllDay      bool      // The line only has a date, Start is that day.
+}
+
+// lineEvent makes an Event out of the entities of one line, if it has a place and a date or time.
+func lineEvent(number int, segments []segment) (Event, bool) {
+	var places []string
+	var moments []time.Time
+	var day time.Time
+
+	for _, piece := range segments {
+		entity := piece.entity
+		if entity == nil {
+			continue
+		}
+		switch entity.Kind {
+		case KindAirport, KindCity:
+			if len(places) == 0 || places[len(places)-1] != entity.Value {
+				places = append(places, entity.Value)
+			}
+		case KindTime, KindDateTime:
+			if moment, err := time.Parse(isoLayout, entity.Datetime); err == nil {
+				moments = append(moments, moment)
+			}
+		case KindDate:
+			if date, err := time.Parse("2006-01-02", entity.Datetime); err == nil && day.IsZero() {
+				day = date
+			}
+		}
+	}
+	if len(places) == 0 || (len(moments) == 0 && day.IsZero()) {
+		return Event{}, false
+	}
+
+	event := Event{Line: number, Summary: strings.Join(places, " → "), Location: places[0], Description: renderText(segments)}
+	if len(moments) == 0 {
+		event.Start, event.AllDay = day, true
+		return event, true
+	}
+	event.Start = moments[0]
+	if last := moments[len(moments)-1]; last.After(event.Start) {
+		event.End = last
+	}
+	return event, true
+}
+
+// WriteCalendar writes the events as an iCalendar file with one VEVENT for each. Times are written in UTC, so the
+// offset of every token is kept, and a line with only a date becomes an all-day event.
+func WriteCalendar(output io.Writer, events []Event) error {
+	stamp := time.Now().UTC().Format(icsLayout)
+	lines := []string{"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//itinerary//prettifier//EN", "CALSCALE:GREGORIAN"}
+
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%s-%d@itinerary", event.Start.UTC().Format(icsLayout), event.Line),
+			"DTSTAMP:"+stamp,
+		)
+		if event.AllDay {
+			lines = append(lines, "DTSTART;VALUE=DATE:"+event.Start.Format("20060102"))
+		} else {
+			lines = append(lines, "DTSTART:"+event.Start.UTC().Format(icsLayout))
+			if !event.End.IsZero() {
+				lines = append(lines, "DTEND:"+event.End.UTC().Format(icsLayout))
+			}
+		}
+		lines = append(lines,
+			"SUMMARY:"+icsText(event.Summary),
+			"LOCATION:"+icsText(event.Location),
+			"DESCRIPTION:"+icsText(event.Description),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var calendar strings.Builder
+	for _, line := range lines {
+		calendar.WriteString(icsFold(line) + "\r\n")
+	}
+	_, err := io.WriteString(output, calendar.String())
+	return err
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func icsText(text string) string {
+	return icsEscaper.Replace(text)
+}
+
+// icsFold breaks a content line into lines of at most 75 bytes, each continuation starting with a space. A character
+// is never split.
+func icsFold(line string) string {
+	var folded strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74 // The space at the start of a continuation counts.
+	}
+	folded.WriteString(line)
+	return folded.String()
+}
@@ -0,0 +1,51 @@
+package prettifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic describes a token that looked like an airport code or a date but could not be resolved.
+type Diagnostic struct {
+	Line        int      `json:"line"`
+	Column      int      `json:"column"`
+	Token       string   `json:"token"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"` // Codes the token was likely meant to be.
+	Correction  string   `json:"correction,omitempty"`  // The code the token was replaced with when it was corrected.
+}
+
+func (d Diagnostic) String() string {
+	message := fmt.Sprintf("line %d, col %d: %s %q", d.Line, d.Column, d.Message, d.Token)
+	if d.Correction != "" {
+		return message + ", corrected to " + d.Correction
+	}
+	if len(d.Suggestions) > 0 {
+		return message + ", did you mean " + strings.Join(d.Suggestions, " or ") + "?"
+	}
+	return message
+}
+
+// unresolved leaves out the diagnostics of tokens that were corrected.
+func unresolved(diagnostics []Diagnostic) []Diagnostic {
+	var left []Diagnostic
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Correction == "" {
+			left = append(left, diagnostic)
+		}
+	}
+	return left
+}
+
+// DiagnosticsError is returned by Prettify in strict mode when any token could not be resolved.
+type DiagnosticsError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *DiagnosticsError) Error() string {
+	report := make([]string, len(e.Diagnostics))
+	for i, diagnostic := range e.Diagnostics {
+		report[i] = diagnostic.String()
+	}
+	return fmt.Sprintf("%d unresolved tokens:\n%s", len(e.Diagnostics), strings.Join(report, "\n"))
+}
@@ -0,0 +1,114 @@
+package prettifier
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const earthRadius = 6371.0 // Mean radius of the earth in kilometres.
+
+const kilometresPerMile = 1.609344
+
+var (
+	distancePattern = regexp.MustCompile(`^DIST\(([A-Z0-9]{3,4}),([A-Z0-9]{3,4})\)$`)
+	distanceToken   = regexp.MustCompile(`^DIST\(`)
+)
+
+// Leg is a flight between two airports that follow each other in an itinerary.
+type Leg struct {
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	FromCode   string  `json:"from_code"`
+	ToCode     string  `json:"to_code"`
+	Kilometres float64 `json:"km,omitempty"` // Zero when the coordinates of either airport are unknown.
+}
+
+// parseCoordinates reads the "longitude, latitude" form of the coordinates column.
+func parseCoordinates(coordinates string) (float64, float64, bool) {
+	parts := strings.Split(coordinates, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return 0, 0, false
+	}
+	return latitude, longitude, true
+}
+
+// greatCircle gives the distance between two airports in kilometres with the haversine formula.
+func greatCircle(from, to *Airport) (float64, bool) {
+	if !from.positioned || !to.positioned {
+		return 0, false
+	}
+	radians := math.Pi / 180
+	latitude := (to.latitude - from.latitude) * radians
+	longitude := (to.longitude - from.longitude) * radians
+
+	a := math.Sin(latitude/2)*math.Sin(latitude/2) +
+		math.Cos(from.latitude*radians)*math.Cos(to.latitude*radians)*math.Sin(longitude/2)*math.Sin(longitude/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a)), true
+}
+
+func formatKilometres(kilometres float64) string {
+	return fmt.Sprintf("%.0f km (%.0f mi)", kilometres, kilometres/kilometresPerMile)
+}
+
+// code gives the IATA code of the airport, or the ICAO code when it has none.
+func (a *Airport) code() string {
+	if a.IATA != "" {
+		return a.IATA
+	}
+	return a.ICAO
+}
+
+// formatDistance resolves a DIST(HEL,TLL) token, the codes can be IATA or ICAO codes.
+func (p *Prettifier) formatDistance(raw string) (Entity, error) {
+	match := distancePattern.FindStringSubmatch(raw)
+	if match == nil {
+		return Entity{}, errors.New("malformed distance")
+	}
+
+	var ends [2]*Airport
+	for i, code := range match[1:] {
+		found, ok := p.airports.IATA(code)
+		if len(code) == 4 {
+			found, ok = p.airports.ICAO(code)
+		}
+		if !ok {
+			return Entity{}, fmt.Errorf("unknown airport %s", code)
+		}
+		ends[i] = found
+	}
+
+	kilometres, ok := greatCircle(ends[0], ends[1])
+	if !ok {
+		return Entity{}, errors.New("airport coordinates unknown")
+	}
+	return Entity{Kind: KindDistance, Value: formatKilometres(kilometres), Code: match[1] + "-" + match[2]}, nil
+}
+
+// legSummary renders the legs as the lines added to the end of an itinerary.
+func (p *Prettifier) legSummary(legs []Leg) []string {
+	locale := p.locale()
+	lines := []string{"", locale.Legs + ":"}
+	total := 0.0
+
+	for _, leg := range legs {
+		line := fmt.Sprintf("%s (%s) → %s (%s)", leg.From, leg.FromCode, leg.To, leg.ToCode)
+		if leg.Kilometres > 0 {
+			line += ", " + formatKilometres(leg.Kilometres)
+		}
+		total += leg.Kilometres
+		lines = append(lines, line)
+	}
+	return append(lines, locale.Total+": "+formatKilometres(total))
+}
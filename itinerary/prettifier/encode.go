@@ -0,0 +1,376 @@
+package prettifier
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// encodeRule turns text written with one locale layout back into a token.
+type encodeRule struct {
+	token   string
+	pattern *regexp.Regexp
+}
+
+// encodeMatch is a date or time found on a line, start and end are byte offsets.
+type encodeMatch struct {
+	start, end int
+	rule       encodeRule
+	groups     map[string]string
+}
+
+// phrase is an airport name or municipality and the code that replaces it.
+type phrase struct {
+	text string
+	code string
+}
+
+// encoder holds what Encode looks for, built once per call from the locale and the airport lookup.
+type encoder struct {
+	locale   *Locale
+	rules    []encodeRule
+	phrases  map[string][]phrase // By the first word of the phrase, longest phrase first.
+	date     string              // Date of the last date token, used by the times after it.
+	warnings io.Writer           // Where times left as text are reported, nil means nowhere.
+}
+
+// Encode is the reverse of Prettify. It reads an itinerary written in plain language and writes it with airport names
+// from the lookup replaced by #IATA and ##ICAO codes, municipalities by *# codes with Options.EncodeCities, and dates
+// and times written in the layouts of the Locale replaced by D, DL, T12, T24, DT12 and DT24 tokens. Times are only
+// recognized with their offset, as in 09:30 (+03:00). A time needs a date before it in the itinerary, as its token
+// carries one, otherwise it is left as text and reported to Options.Warnings. The output is always plain text.
+func (p *Prettifier) Encode(input io.Reader, output io.Writer) error {
+	e := p.encoder()
+	lines := inputRead(input, p.whitespace())
+	writer := bufio.NewWriter(output)
+
+	for number := 1; ; number++ {
+		line, ok, err := lines.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		// Lines are joined the way the text format joins them, without a line break after the last one.
+		if number > 1 {
+			line = "\n" + e.line(number, line)
+		} else {
+			line = e.line(number, line)
+		}
+		if _, err := writer.WriteString(line); err != nil {
+			return err
+		}
+		// Flushing every line keeps a pipe flowing, the same as Prettify.
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Prettifier) encoder() *encoder {
+	locale := p.locale()
+	offset := ` \((?P<offset>[-+]\d{2}:\d{2})\)`
+	dateTime := func(clock string) string {
+		return strings.NewReplacer(
+			regexp.QuoteMeta("{date}"), layoutPattern(locale.Date, locale),
+			regexp.QuoteMeta("{time}"), layoutPattern(clock, locale)+offset,
+		).Replace(regexp.QuoteMeta(locale.DateTime))
+	}
+
+	// The longer layouts come first, so a date and time is not taken for a date followed by a time.
+	e := &encoder{locale: locale, phrases: make(map[string][]phrase), warnings: p.options.Warnings}
+	for _, rule := range []struct{ token, pattern string }{
+		{"DT24", dateTime(locale.Time24)},
+		{"DT12", dateTime(locale.Time12)},
+		{"DL", layoutPattern(locale.LongDate, locale)},
+		{"D", layoutPattern(locale.Date, locale)},
+		{"T24", layoutPattern(locale.Time24, locale) + offset},
+		{"T12", layoutPattern(locale.Time12, locale) + offset},
+	} {
+		e.rules = append(e.rules, encodeRule{token: rule.token, pattern: regexp.MustCompile(rule.pattern)})
+	}
+
+	for _, airport := range p.airports.byIATA {
+		e.addPhrase(airport.Name, "#"+airport.IATA)
+	}
+	for _, airport := range p.airports.byICAO {
+		if airport.IATA == "" {
+			e.addPhrase(airport.Name, "##"+airport.ICAO)
+		}
+	}
+	for municipality, airports := range p.airports.byMunicipality {
+		if !p.options.EncodeCities || severalCountries(airports) {
+			continue
+		}
+		// The first airport of the municipality is the one the lookup lists first, the same as for a repeated code.
+		if airports[0].IATA != "" {
+			e.addPhrase(municipality, "*#"+airports[0].IATA)
+		} else {
+			e.addPhrase(municipality, "*##"+airports[0].ICAO)
+		}
+	}
+	for key := range e.phrases {
+		sort.Slice(e.phrases[key], func(i, j int) bool {
+			a, b := e.phrases[key][i], e.phrases[key][j]
+			if len(a.text) != len(b.text) {
+				return len(a.text) > len(b.text)
+			}
+			return a.code < b.code
+		})
+	}
+	return e
+}
+
+// severalCountries tells whether the airports of a municipality are in more than one country, so its name alone does not
+// say which place is meant.
+func severalCountries(airports []*Airport) bool {
+	for _, airport := range airports {
+		if airport.Country != airports[0].Country {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutPattern turns a locale layout into a regular expression with a named group for every placeholder.
+func layoutPattern(layout string, locale *Locale) string {
+	names := func(list []string) string {
+		quoted := make([]string, len(list))
+		for i, name := range list {
+			quoted[i] = regexp.QuoteMeta(name)
+		}
+		return strings.Join(quoted, "|")
+	}
+	groups := map[string]string{
+		"{dd}":      `(?P<d>\d{2})`,
+		"{d}":       `(?P<d>\d{1,2})`,
+		"{mon}":     `(?P<mon>` + names(locale.Months[:]) + `)`,
+		"{month}":   `(?P<month>` + names(locale.LongMonths[:]) + `)`,
+		"{weekday}": `(?:` + names(locale.Weekdays[:]) + `)`,
+		"{yyyy}":    `(?P<yyyy>\d{4})`,
+		"{HH}":      `(?P<H>\d{2})`,
+		"{H}":       `(?P<H>\d{1,2})`,
+		"{hh}":      `(?P<h>\d{2})`,
+		"{h}":       `(?P<h>\d{1,2})`,
+		"{mm}":      `(?P<mm>\d{2})`,
+		"{ampm}":    `(?P<ampm>` + names([]string{locale.AM, locale.PM}) + `)`,
+	}
+
+	var pattern strings.Builder
+	last := 0
+	for _, span := range placeholderPattern.FindAllStringIndex(layout, -1) {
+		pattern.WriteString(regexp.QuoteMeta(layout[last:span[0]]))
+		if group, ok := groups[layout[span[0]:span[1]]]; ok {
+			pattern.WriteString(group)
+		} else {
+			pattern.WriteString(regexp.QuoteMeta(layout[span[0]:span[1]]))
+		}
+		last = span[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(layout[last:]))
+	return pattern.String()
+}
+
+func (e *encoder) addPhrase(text, code string) {
+	key := leadingWord(text)
+	if key == "" {
+		return
+	}
+	e.phrases[key] = append(e.phrases[key], phrase{text: text, code: code})
+}
+
+// leadingWord is the run of letters and digits at the start of the text.
+func leadingWord(text string) string {
+	end := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if end < 0 {
+		return text
+	}
+	return text[:end]
+}
+
+// line encodes one line, number is the line number counted from 1. Dates and times are found first, then airports and
+// municipalities in the text between them. Only text that starts and ends a word is replaced, as lex would not find a
+// token that carries on a word.
+func (e *encoder) line(number int, line string) string {
+	var matches []encodeMatch
+	for _, rule := range e.rules {
+		for _, span := range rule.pattern.FindAllStringSubmatchIndex(line, -1) {
+			if !standsAlone(line, span[0], span[1]) || overlaps(matches, span[0], span[1]) {
+				continue
+			}
+			groups := make(map[string]string)
+			for i, name := range rule.pattern.SubexpNames() {
+				if name != "" && span[2*i] >= 0 {
+					groups[name] = line[span[2*i]:span[2*i+1]]
+				}
+			}
+			matches = append(matches, encodeMatch{start: span[0], end: span[1], rule: rule, groups: groups})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	var encoded strings.Builder
+	last := 0
+	for _, match := range matches {
+		if match.groups["yyyy"] == "" && e.date == "" {
+			if e.warnings != nil {
+				column := utf8.RuneCountInString(line[:match.start]) + 1
+				diagnostic := Diagnostic{Line: number, Column: column, Token: line[match.start:match.end], Message: "no date before time"}
+				fmt.Fprintln(e.warnings, "warning: "+diagnostic.String())
+			}
+			continue
+		}
+		token, ok := e.token(match)
+		if !ok {
+			continue
+		}
+		encoded.WriteString(escapeBefore(e.airports(line[last:match.start], line[:last]), line[:match.start]))
+		encoded.WriteString(token)
+		last = match.end
+	}
+	encoded.WriteString(e.airports(line[last:], line[:last]))
+	return encoded.String()
+}
+
+// token writes the date or time of a match as a token. The date of a date token is remembered for the times after it.
+func (e *encoder) token(match encodeMatch) (string, bool) {
+	groups := match.groups
+	date := e.date
+	if groups["yyyy"] != "" {
+		month := 0
+		for i := range e.locale.Months {
+			if groups["mon"] == e.locale.Months[i] || groups["month"] == e.locale.LongMonths[i] {
+				month = i + 1
+			}
+		}
+		day, _ := strconv.Atoi(groups["d"])
+		if month == 0 || day < 1 || day > 31 {
+			return "", false
+		}
+		date = fmt.Sprintf("%s-%02d-%02d", groups["yyyy"], month, day)
+		e.date = date
+	}
+
+	clock := "00:00"
+	offset := "Z"
+	if groups["mm"] != "" {
+		hour, _ := strconv.Atoi(groups["H"])
+		if groups["h"] != "" {
+			hour, _ = strconv.Atoi(groups["h"])
+			if hour < 1 || hour > 12 {
+				return "", false
+			}
+			hour %= 12
+			if groups["ampm"] == e.locale.PM {
+				hour += 12
+			}
+		}
+		if hour > 23 || groups["mm"] > "59" {
+			return "", false
+		}
+		clock = fmt.Sprintf("%02d:%s", hour, groups["mm"])
+		offset = groups["offset"]
+	}
+	return match.rule.token + "(" + date + "T" + clock + offset + ")", true
+}
+
+// airports replaces the airport names and municipalities in a piece of text, before is the text of the line in front of
+// it. The longest name wins where several start at the same word.
+func (e *encoder) airports(text, before string) string {
+	var encoded strings.Builder
+	last := 0
+	for start := 0; start < len(text); {
+		previous := before
+		if start > 0 {
+			previous = text[:start]
+		}
+		if key := leadingWord(text[start:]); key != "" && startsWord(previous) {
+			replaced := false
+			for _, candidate := range e.phrases[key] {
+				end := start + len(candidate.text)
+				if strings.HasPrefix(text[start:], candidate.text) && wordEnds(text, end) {
+					encoded.WriteString(escapeBefore(escapeText(text[last:start]), previous) + candidate.code)
+					start, last, replaced = end, end, true
+					break
+				}
+			}
+			if replaced {
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+	encoded.WriteString(escapeText(text[last:]))
+	return encoded.String()
+}
+
+// escapeText escapes the words of a piece of plain text that Prettify would otherwise read as tokens, so a literal
+// "Gate #12" stays as it is.
+func escapeText(text string) string {
+	words := strings.Split(text, " ")
+	for i, word := range words {
+		words[i] = escape(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// escape writes a backslash before every escapable character of a word in which lex finds a token or an escape. Other
+// words are returned as they are.
+func escape(word string) string {
+	if lexemes := lex(word); len(lexemes) == 0 || (len(lexemes) == 1 && !lexemes[0].token && lexemes[0].text == word) {
+		return word
+	}
+	var escaped strings.Builder
+	for i := 0; i < len(word); i++ {
+		if strings.IndexByte(escapable, word[i]) >= 0 {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(word[i])
+	}
+	return escaped.String()
+}
+
+// escapeBefore finishes the encoded text written right in front of a token, before is the plain text it came from. A
+// backslash at its end would keep the token from starting, so it is escaped unless escapeText already did.
+func escapeBefore(encoded, before string) string {
+	if strings.HasSuffix(before, `\`) && !strings.HasSuffix(encoded, `\\`) {
+		return encoded + `\`
+	}
+	return encoded
+}
+
+// standsAlone tells whether line[start:end] starts a word and ends one.
+func standsAlone(line string, start, end int) bool {
+	return startsWord(line[:start]) && wordEnds(line, end)
+}
+
+// startsWord tells whether a token could start right after the text in front of it. A backslash in front does not
+// stop it, escapeBefore escapes the backslash when the token is written.
+func startsWord(before string) bool {
+	previous, _ := utf8.DecodeLastRuneInString(before)
+	return before == "" || previous == '\\' || wordBoundary(previous)
+}
+
+// wordEnds tells whether text[end:] does not carry on the word, so "Tallinn," ends at the comma but "Tallinnas" does not.
+func wordEnds(text string, end int) bool {
+	r, _ := utf8.DecodeRuneInString(text[end:])
+	return end == len(text) || (!unicode.IsLetter(r) && !unicode.IsDigit(r))
+}
+
+func overlaps(matches []encodeMatch, start, end int) bool {
+	for _, match := range matches {
+		if start < match.end && match.start < end {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package prettifier
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escapable are the characters a backslash makes literal.
+const escapable = `\#*()`
+
+// tokenStart is the name of a date, time, duration or distance token and its opening parenthesis.
+var tokenStart = regexp.MustCompile(`^(?:D|DL|DT12|DT24|T12|T24|DUR|DIST)\(`)
+
+// lexeme is a piece of a line, either plain text with its escapes removed or a token exactly as it was written.
+type lexeme struct {
+	text  string
+	token bool
+	start int // Byte offset of the lexeme in the line.
+}
+
+// lex splits a line into text and tokens. A token can start anywhere a word could start, at the beginning of the line
+// or after a character that is not a letter, a digit, a backslash, * or #, so codes in parentheses, in quotes or
+// followed by a colon are found. Every character around a token is kept exactly as it was, except that the escapes
+// \#, \*, \(, \) and \\ become the character after the backslash and never start a token themselves.
+func lex(line string) []lexeme {
+	var lexemes []lexeme
+	var text strings.Builder
+	textStart := 0
+	flush := func() {
+		if text.Len() > 0 {
+			lexemes = append(lexemes, lexeme{text: text.String(), start: textStart})
+			text.Reset()
+		}
+	}
+
+	previous := ' '
+	for i := 0; i < len(line); {
+		if line[i] == '\\' && i+1 < len(line) && strings.IndexByte(escapable, line[i+1]) >= 0 {
+			if text.Len() == 0 {
+				textStart = i
+			}
+			text.WriteByte(line[i+1])
+			// The escaped character counts as written, except that an escaped backslash does not keep a token from
+			// starting the way a backslash of its own does, so \\#HEL is a backslash and an airport.
+			previous = rune(line[i+1])
+			if previous == '\\' {
+				previous = ' '
+			}
+			i += 2
+			continue
+		}
+
+		if wordBoundary(previous) {
+			if end := tokenEnd(line, i); end > i {
+				flush()
+				lexemes = append(lexemes, lexeme{text: line[i:end], token: true, start: i})
+				previous, _ = utf8.DecodeLastRuneInString(line[i:end])
+				i = end
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if text.Len() == 0 {
+			textStart = i
+		}
+		text.WriteString(line[i : i+size])
+		previous = r
+		i += size
+	}
+	flush()
+	return lexemes
+}
+
+func wordBoundary(previous rune) bool {
+	return !unicode.IsLetter(previous) && !unicode.IsDigit(previous) && !strings.ContainsRune(`\#*`, previous)
+}
+
+// tokenEnd gives the byte offset where a token starting at line[start] ends, or start when no token starts there. A
+// date, time or distance token runs to its closing parenthesis, or to the next space when it has none, so the
+// malformed token is reported whole. An airport code is its #, ## or *# and all the letters and digits after it.
+func tokenEnd(line string, start int) int {
+	rest := line[start:]
+	if tokenStart.MatchString(rest) {
+		if end := strings.IndexByte(rest, ')'); end >= 0 {
+			return start + end + 1
+		}
+		if end := strings.IndexByte(rest, ' '); end >= 0 {
+			return start + end
+		}
+		return len(line)
+	}
+
+	prefix := 0
+	switch {
+	case strings.HasPrefix(rest, "*##"):
+		prefix = 3
+	case strings.HasPrefix(rest, "##"), strings.HasPrefix(rest, "*#"):
+		prefix = 2
+	case strings.HasPrefix(rest, "#"):
+		prefix = 1
+	default:
+		return start
+	}
+	// The whole run of letters and digits is taken, not only ASCII, so #HAJé is one unknown code and not #HAJ.
+	end := prefix
+	for end < len(rest) {
+		r, size := utf8.DecodeRuneInString(rest[end:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		end += size
+	}
+	if end == prefix {
+		return start
+	}
+	return start + end
+}
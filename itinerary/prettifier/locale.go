@@ -0,0 +1,146 @@
+package prettifier
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale describes how dates and times are written in one language.
+//
+// The layouts use placeholders that are replaced when a token is rendered:
+// {dd} and {d} for the day with and without a leading zero, {mon} and {month} for the short and long month name,
+// {weekday} for the day of the week, {yyyy} for the year, {HH} and {H} for the 24-hour clock, {hh} and {h} for the
+// 12-hour clock, {mm} for minutes and {ampm} for the AM or PM marker. DateTime joins a rendered {date} and {time}
+// and Duration takes {hours} and {minutes}.
+type Locale struct {
+	Months     [12]string
+	LongMonths [12]string
+	Weekdays   [7]string // Starting from Sunday, like time.Weekday.
+	Date       string
+	LongDate   string
+	Time12     string
+	Time24     string
+	DateTime   string
+	Duration   string
+	AM         string
+	PM         string
+	Legs       string // Heading of the leg summary.
+	Total      string // Label of the total distance in the leg summary.
+}
+
+// Locales holds the built-in locales by name. English is the default.
+var Locales = map[string]*Locale{
+	"en": {
+		Months:     [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		LongMonths: [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		Weekdays:   [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		Date:       "{dd} {mon} {yyyy}",
+		LongDate:   "{weekday}, {dd} {month} {yyyy}",
+		Time12:     "{hh}:{mm}{ampm}",
+		Time24:     "{HH}:{mm}",
+		DateTime:   "{date}, {time}",
+		Duration:   "{hours}h {minutes}m",
+		AM:         "AM",
+		PM:         "PM",
+		Legs:       "Flight legs",
+		Total:      "Total",
+	},
+	"et": {
+		Months:     [12]string{"jaan", "veebr", "märts", "apr", "mai", "juuni", "juuli", "aug", "sept", "okt", "nov", "dets"},
+		LongMonths: [12]string{"jaanuar", "veebruar", "märts", "aprill", "mai", "juuni", "juuli", "august", "september", "oktoober", "november", "detsember"},
+		Weekdays:   [7]string{"pühapäev", "esmaspäev", "teisipäev", "kolmapäev", "neljapäev", "reede", "laupäev"},
+		Date:       "{d}. {mon} {yyyy}",
+		LongDate:   "{weekday}, {d}. {month} {yyyy}",
+		Time12:     "{h}:{mm} {ampm}",
+		Time24:     "{HH}:{mm}",
+		DateTime:   "{date} kell {time}",
+		Duration:   "{hours} h {minutes} min",
+		AM:         "e.l.",
+		PM:         "p.l.",
+		Legs:       "Lennulõigud",
+		Total:      "Kokku",
+	},
+	"de": {
+		Months:     [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		LongMonths: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		Weekdays:   [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		Date:       "{d}. {mon} {yyyy}",
+		LongDate:   "{weekday}, {d}. {month} {yyyy}",
+		Time12:     "{h}:{mm} {ampm}",
+		Time24:     "{HH}:{mm} Uhr",
+		DateTime:   "{date}, {time}",
+		Duration:   "{hours} Std. {minutes} Min.",
+		AM:         "vorm.",
+		PM:         "nachm.",
+		Legs:       "Flugabschnitte",
+		Total:      "Gesamt",
+	},
+	"fi": {
+		Months:     [12]string{"tammik.", "helmik.", "maalisk.", "huhtik.", "toukok.", "kesäk.", "heinäk.", "elok.", "syysk.", "lokak.", "marrask.", "jouluk."},
+		LongMonths: [12]string{"tammikuuta", "helmikuuta", "maaliskuuta", "huhtikuuta", "toukokuuta", "kesäkuuta", "heinäkuuta", "elokuuta", "syyskuuta", "lokakuuta", "marraskuuta", "joulukuuta"},
+		Weekdays:   [7]string{"sunnuntai", "maanantai", "tiistai", "keskiviikko", "torstai", "perjantai", "lauantai"},
+		Date:       "{d}. {mon} {yyyy}",
+		LongDate:   "{weekday} {d}. {month} {yyyy}",
+		Time12:     "{h}.{mm} {ampm}",
+		Time24:     "{H}.{mm}",
+		DateTime:   "{date} klo {time}",
+		Duration:   "{hours} h {minutes} min",
+		AM:         "ap.",
+		PM:         "ip.",
+		Legs:       "Lentoosuudet",
+		Total:      "Yhteensä",
+	},
+}
+
+// LookupLocale returns the built-in locale with the name, for example "et" or "de".
+func LookupLocale(name string) (*Locale, error) {
+	locale, ok := Locales[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for known := range Locales {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown locale %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return locale, nil
+}
+
+func (l *Locale) render(layout string, moment time.Time) string {
+	hour12 := moment.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+	marker := l.AM
+	if moment.Hour() >= 12 {
+		marker = l.PM
+	}
+
+	replacer := strings.NewReplacer(
+		"{dd}", fmt.Sprintf("%02d", moment.Day()),
+		"{d}", strconv.Itoa(moment.Day()),
+		"{mon}", l.Months[moment.Month()-1],
+		"{month}", l.LongMonths[moment.Month()-1],
+		"{weekday}", l.Weekdays[moment.Weekday()],
+		"{yyyy}", strconv.Itoa(moment.Year()),
+		"{HH}", fmt.Sprintf("%02d", moment.Hour()),
+		"{H}", strconv.Itoa(moment.Hour()),
+		"{hh}", fmt.Sprintf("%02d", hour12),
+		"{h}", strconv.Itoa(hour12),
+		"{mm}", fmt.Sprintf("%02d", moment.Minute()),
+		"{ampm}", marker,
+	)
+	return replacer.Replace(layout)
+}
+
+func (l *Locale) renderDuration(length time.Duration) string {
+	minutes := int(length.Minutes())
+	replacer := strings.NewReplacer(
+		"{hours}", strconv.Itoa(minutes/60),
+		"{minutes}", strconv.Itoa(minutes%60),
+	)
+	return replacer.Replace(l.Duration)
+}
@@ -0,0 +1,101 @@
+package prettifier
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Conflict is a code that two merged lookups give different airports for. The airport of the later lookup is used.
+type Conflict struct {
+	Code     string
+	Airport  *Airport // The airport the code resolves to.
+	Replaced *Airport // The airport of the earlier lookup that is no longer used for the code.
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s is %q from %s instead of %q from %s", c.Code, c.Airport.Name, sourceName(c.Airport), c.Replaced.Name, sourceName(c.Replaced))
+}
+
+func sourceName(entry *Airport) string {
+	if entry.source == "" {
+		return "an unnamed lookup"
+	}
+	return entry.source
+}
+
+// LoadAirportsFiles loads every route and merges them with MergeAirports in the order they are given. A directory
+// stands for the .csv files in it in the order of their names, so 10-overrides.csv goes on top of 00-public.csv.
+func LoadAirportsFiles(routes ...string) (*Airports, []Conflict, error) {
+	var lookups []*Airports
+	for _, route := range routes {
+		files := []string{route}
+		if info, err := os.Stat(route); err == nil && info.IsDir() {
+			files, err = filepath.Glob(filepath.Join(route, "*.csv"))
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(files) == 0 {
+				return nil, nil, fmt.Errorf("%s: no csv files: %w", route, os.ErrNotExist)
+			}
+			sort.Strings(files)
+		}
+
+		for _, file := range files {
+			airports, err := LoadAirportsFile(file)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", file, err)
+			}
+			lookups = append(lookups, airports)
+		}
+	}
+	merged, conflicts := MergeAirports(lookups...)
+	return merged, conflicts, nil
+}
+
+// MergeAirports combines lookups into one index. A code that appears in several lookups resolves to the airport of the
+// last one, so an override lookup goes after the public dataset. Within one lookup the first row still wins. Every code
+// that the lookups give different airports for is returned as a Conflict, a row repeated unchanged is not one.
+func MergeAirports(lookups ...*Airports) (*Airports, []Conflict) {
+	merged := &Airports{
+		byIATA:         make(map[string]*Airport),
+		byICAO:         make(map[string]*Airport),
+		byMunicipality: make(map[string][]*Airport),
+	}
+	var conflicts []Conflict
+
+	for _, lookup := range lookups {
+		conflicts = append(conflicts, replaceCodes(merged.byIATA, lookup.byIATA)...)
+		conflicts = append(conflicts, replaceCodes(merged.byICAO, lookup.byICAO)...)
+		merged.all = append(merged.all, lookup.all...)
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Code < conflicts[j].Code })
+
+	// A municipality lists the airports of the later lookups first, leaving out rows whose codes now belong to others.
+	for i := len(lookups) - 1; i >= 0; i-- {
+		for _, entry := range lookups[i].all {
+			current := merged.byIATA[entry.IATA] == entry || merged.byICAO[entry.ICAO] == entry
+			if entry.Municipality != "" && current {
+				merged.byMunicipality[entry.Municipality] = append(merged.byMunicipality[entry.Municipality], entry)
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+func replaceCodes(merged, lookup map[string]*Airport) []Conflict {
+	var conflicts []Conflict
+	for code, entry := range lookup {
+		if replaced, ok := merged[code]; ok && !sameAirport(replaced, entry) {
+			conflicts = append(conflicts, Conflict{Code: code, Airport: entry, Replaced: replaced})
+		}
+		merged[code] = entry
+	}
+	return conflicts
+}
+
+func sameAirport(a, b *Airport) bool {
+	return a.Name == b.Name && a.Country == b.Country && a.Municipality == b.Municipality && a.ICAO == b.ICAO &&
+		a.IATA == b.IATA && a.Coordinates == b.Coordinates && a.Timezone == b.Timezone
+}
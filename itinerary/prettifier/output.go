@@ -0,0 +1,228 @@
+package prettifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Format is the kind of document Prettify writes.
+type Format string
+
+// The supported output formats.
+const (
+	FormatText     Format = "text"
+	FormatHTML     Format = "html"
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "markdown"
+)
+
+// ParseFormat returns the Format with the name, for example "html".
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatText, FormatHTML, FormatJSON, FormatMarkdown:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected text, html, json or markdown", name)
+}
+
+// Extension returns the usual file extension of the format, with the dot.
+func (f Format) Extension() string {
+	switch f {
+	case FormatHTML:
+		return ".html"
+	case FormatJSON:
+		return ".json"
+	case FormatMarkdown:
+		return ".md"
+	}
+	return ".txt"
+}
+
+// lineWriter writes a processed itinerary one line at a time in one of the formats. close is called once after the
+// last line with the report of the itinerary and the lines of the leg summary, which is nil when it was not asked for.
+type lineWriter interface {
+	line(segments []segment) error
+	close(report Report, summary []string) error
+}
+
+func outputWrite(output io.Writer, format Format) lineWriter {
+	switch format {
+	case FormatHTML:
+		return &htmlWriter{output: output}
+	case FormatMarkdown:
+		return &markdownWriter{output: output}
+	case FormatJSON:
+		return &jsonWriter{output: output, document: jsonDocument{Entities: []Entity{}, Diagnostics: []Diagnostic{}}}
+	}
+	return &textWriter{output: output}
+}
+
+func renderText(segments []segment) string {
+	var builder strings.Builder
+	for _, piece := range segments {
+		if piece.entity != nil {
+			builder.WriteString(piece.entity.Value)
+		} else {
+			builder.WriteString(piece.text)
+		}
+	}
+	return builder.String()
+}
+
+// textWriter writes the prettified text, the lines are joined with newlines and there is none after the last.
+type textWriter struct {
+	output  io.Writer
+	started bool
+}
+
+func (w *textWriter) line(segments []segment) error {
+	text := renderText(segments)
+	if w.started {
+		text = "\n" + text
+	}
+	w.started = true
+	_, err := io.WriteString(w.output, text)
+	return err
+}
+
+func (w *textWriter) close(report Report, summary []string) error {
+	for _, line := range summary {
+		if err := w.line([]segment{{text: line}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// htmlWriter wraps airports in abbr elements with the code as a tooltip and dates, times and durations in time
+// elements, everything else is escaped.
+type htmlWriter struct {
+	output  io.Writer
+	started bool
+}
+
+func (w *htmlWriter) line(segments []segment) error {
+	var builder strings.Builder
+	if w.started {
+		builder.WriteString("<br>\n")
+	} else {
+		builder.WriteString("<div class=\"itinerary\">\n")
+	}
+	w.started = true
+
+	for _, piece := range segments {
+		switch {
+		case piece.entity == nil:
+			builder.WriteString(html.EscapeString(piece.text))
+		case piece.entity.Kind == KindAirport || piece.entity.Kind == KindCity:
+			fmt.Fprintf(&builder, `<abbr class="%s" title="%s">%s</abbr>`, piece.entity.Kind, html.EscapeString(piece.entity.Code), html.EscapeString(piece.entity.Value))
+		case piece.entity.Kind == KindDistance:
+			fmt.Fprintf(&builder, `<span class="%s" title="%s">%s</span>`, piece.entity.Kind, html.EscapeString(piece.entity.Code), html.EscapeString(piece.entity.Value))
+		default:
+			fmt.Fprintf(&builder, `<time class="%s" datetime="%s">%s</time>`, piece.entity.Kind, html.EscapeString(piece.entity.Datetime), html.EscapeString(piece.entity.Value))
+		}
+	}
+	_, err := io.WriteString(w.output, builder.String())
+	return err
+}
+
+func (w *htmlWriter) close(report Report, summary []string) error {
+	for _, line := range summary {
+		if err := w.line([]segment{{text: line}}); err != nil {
+			return err
+		}
+	}
+
+	ending := "\n</div>\n"
+	if !w.started {
+		ending = "<div class=\"itinerary\">\n" + ending
+	}
+	_, err := io.WriteString(w.output, ending)
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`)
+
+// markdownWriter makes the resolved tokens bold and ends lines with two spaces so that the line breaks are kept. A line
+// is held back until the next one is seen, because the two spaces only go between two lines that are not blank.
+type markdownWriter struct {
+	output  io.Writer
+	held    string
+	started bool
+}
+
+func (w *markdownWriter) line(segments []segment) error {
+	var builder strings.Builder
+	for _, piece := range segments {
+		if piece.entity != nil {
+			builder.WriteString("**" + markdownEscaper.Replace(piece.entity.Value) + "**")
+		} else {
+			builder.WriteString(markdownEscaper.Replace(piece.text))
+		}
+	}
+	current := builder.String()
+
+	if w.started {
+		previous := w.held
+		if previous != "" && current != "" {
+			previous += "  "
+		}
+		if _, err := io.WriteString(w.output, previous+"\n"); err != nil {
+			return err
+		}
+	}
+	w.held = current
+	w.started = true
+	return nil
+}
+
+func (w *markdownWriter) close(report Report, summary []string) error {
+	for _, line := range summary {
+		if err := w.line([]segment{{text: line}}); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w.output, w.held)
+	return err
+}
+
+// jsonDocument is the shape of the json output.
+type jsonDocument struct {
+	Text        string       `json:"text"`
+	Entities    []Entity     `json:"entities"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Legs        []Leg        `json:"legs,omitempty"`
+}
+
+// jsonWriter has to see the whole itinerary before it can write the single json object.
+type jsonWriter struct {
+	output   io.Writer
+	document jsonDocument
+	lines    []string
+}
+
+func (w *jsonWriter) line(segments []segment) error {
+	w.lines = append(w.lines, renderText(segments))
+	for _, piece := range segments {
+		if piece.entity != nil {
+			w.document.Entities = append(w.document.Entities, *piece.entity)
+		}
+	}
+	return nil
+}
+
+func (w *jsonWriter) close(report Report, summary []string) error {
+	w.document.Text = strings.Join(w.lines, "\n")
+	w.document.Diagnostics = append(w.document.Diagnostics, report.Diagnostics...)
+	if summary != nil {
+		w.document.Legs = append([]Leg{}, report.Legs...)
+	}
+
+	encoder := json.NewEncoder(w.output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(w.document)
+}
@@ -0,0 +1,150 @@
+// Package prettifier turns a coded itinerary into customer-friendly text. Airport codes such as #HEL or ##EFHK are
+// replaced by airport names from a lookup and ISO 8601 timestamps in D(...), T12(...) and T24(...) are formatted.
+package prettifier
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Options holds the settings of a Prettifier, the zero value gives the default output.
+type Options struct {
+	Locale    *Locale   // Language of dates and times, nil means English.
+	ZoneNames bool      // Write zone abbreviations like EEST instead of offsets when the zone of an airport is known.
+	Format    Format    // Kind of document to write, empty means plain text.
+	Strict    bool      // Fail with a DiagnosticsError instead of writing output when a token cannot be resolved.
+	Warnings  io.Writer // Where unresolved tokens are reported when not strict, nil means nowhere.
+	Legs      bool      // Add a summary of the flight legs and their distances to the end.
+
+	// Whitespace holds the rules the lines of the input are normalized with, nil means the classic profile.
+	Whitespace *Whitespace
+
+	// AutoCorrect replaces an unknown airport code when there is exactly one suggestion for it or the code was only
+	// written in the wrong case. The correction is still reported, but it does not fail strict mode.
+	AutoCorrect bool
+
+	// EncodeCities lets Encode replace municipalities with *# codes. It is off by default, as many municipalities are
+	// also common words, like Nice or Mobile, and a municipality whose airports are in several countries, like London,
+	// is left as it is even when it is on.
+	EncodeCities bool
+
+	// AirportTemplate and CityTemplate control how #IATA and ##ICAO words and *# words are written, for example
+	// "{name} ({iata}), {country}". Empty templates mean "{name}" and "{city}", CheckTemplate lists the placeholders.
+	AirportTemplate string
+	CityTemplate    string
+}
+
+// Prettifier converts itineraries using one loaded airport lookup. It is safe to use from several goroutines.
+type Prettifier struct {
+	airports *Airports
+	options  Options
+}
+
+// New returns a Prettifier that resolves airport codes from airports.
+func New(airports *Airports, options Options) *Prettifier {
+	return &Prettifier{airports: airports, options: options}
+}
+
+func (p *Prettifier) locale() *Locale {
+	if p.options.Locale == nil {
+		return Locales["en"]
+	}
+	return p.options.Locale
+}
+
+func (p *Prettifier) whitespace() *Whitespace {
+	if p.options.Whitespace == nil {
+		return WhitespaceProfiles["classic"]
+	}
+	return p.options.Whitespace
+}
+
+// Report sums up one prettified itinerary.
+type Report struct {
+	Lines       int          // Lines of the itinerary after the whitespace rules.
+	Resolved    int          // Tokens that were resolved.
+	Diagnostics []Diagnostic // Tokens that could not be resolved.
+	Legs        []Leg        // Flights between the airports in the order they were mentioned.
+	Events      []Event      // Lines with both a date or time and an airport, for WriteCalendar.
+}
+
+// Prettify reads a coded itinerary from input and writes the prettified itinerary to output in the chosen Format.
+// The input is processed line by line as it is read, except in strict mode, where nothing is written until the whole
+// itinerary has been checked.
+func (p *Prettifier) Prettify(input io.Reader, output io.Writer) error {
+	_, err := p.PrettifyReport(input, output)
+	return err
+}
+
+// PrettifyReport works like Prettify and also returns a Report of the itinerary, even when it fails in strict mode.
+func (p *Prettifier) PrettifyReport(input io.Reader, output io.Writer) (Report, error) {
+	var report Report
+	var held bytes.Buffer
+	destination := output
+	if p.options.Strict {
+		destination = &held
+	}
+
+	lines := inputRead(input, p.whitespace())
+	writer := outputWrite(destination, p.options.Format)
+	var previous *Airport
+
+	for number := 1; ; number++ {
+		line, ok, err := lines.next()
+		if err != nil {
+			return report, err
+		}
+		if !ok {
+			break
+		}
+
+		segments, found := p.processText(number, line)
+		if p.options.Warnings != nil && !p.options.Strict {
+			for _, diagnostic := range found {
+				fmt.Fprintln(p.options.Warnings, "warning: "+diagnostic.String())
+			}
+		}
+		report.Lines++
+		report.Diagnostics = append(report.Diagnostics, found...)
+		if event, ok := lineEvent(number, segments); ok {
+			report.Events = append(report.Events, event)
+		}
+		for _, piece := range segments {
+			if piece.entity == nil {
+				continue
+			}
+			report.Resolved++
+
+			// Every airport that differs from the one before it ends a leg.
+			current := piece.entity.airport
+			if current != nil && previous != nil && current != previous {
+				kilometres, _ := greatCircle(previous, current)
+				report.Legs = append(report.Legs, Leg{From: previous.Name, To: current.Name, FromCode: previous.code(), ToCode: current.code(), Kilometres: kilometres})
+			}
+			if current != nil {
+				previous = current
+			}
+		}
+
+		if err := writer.line(segments); err != nil {
+			return report, err
+		}
+	}
+
+	if failed := unresolved(report.Diagnostics); p.options.Strict && len(failed) > 0 {
+		return report, &DiagnosticsError{Diagnostics: failed}
+	}
+	var summary []string
+	if p.options.Legs {
+		summary = p.legSummary(report.Legs)
+	}
+	if err := writer.close(report, summary); err != nil {
+		return report, err
+	}
+	if p.options.Strict {
+		_, err := held.WriteTo(output)
+		return report, err
+	}
+	return report, nil
+}
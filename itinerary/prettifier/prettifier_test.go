@@ -0,0 +1,578 @@
+package prettifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLookup = `name,iso_country,municipality,icao_code,iata_code,coordinates
+Helsinki Vantaa Airport,FI,Helsinki,EFHK,HEL,"24.963300704956, 60.317199707031"
+Lennart Meri Tallinn Airport,EE,Tallinn,EETN,TLL,"24.832799911499997, 59.41329956049999"
+Hannover Airport,DE,Hannover,EDDV,HAJ,"9.685079574580001, 52.461101532"
+`
+
+func testPrettifier(t *testing.T, options Options) *Prettifier {
+	t.Helper()
+	airports, err := LoadAirports(strings.NewReader(testLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(airports, options)
+}
+
+func prettify(t *testing.T, p *Prettifier, input string) string {
+	t.Helper()
+	var output bytes.Buffer
+	if err := p.Prettify(strings.NewReader(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	return output.String()
+}
+
+func TestPrettify(t *testing.T) {
+	p := testPrettifier(t, Options{})
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Departs from #HEL.", "Departs from Helsinki Vantaa Airport."},
+		{"Arrives at ##EETN, *#HAJ!", "Arrives at Lennart Meri Tallinn Airport, Hannover!"},
+		{"Unknown #XYZ and ##ABCD", "Unknown #XYZ and ##ABCD"},
+		{"On D(2022-05-09T08:07Z).", "On 09 May 2022."},
+		{"At T12(2069-04-24T19:18-02:00) and T24(2080-05-04T14:54Z)", "At 07:18PM (-02:00) and 14:54 (+00:00)"},
+		{"T12(2024-01-01T12:05+02:00) T12(2024-01-01T00:30+02:00)", "12:05PM (+02:00) 12:30AM (+02:00)"},
+		{"D(2022-13-09T08:07Z)", "D(2022-13-09T08:07Z)"},
+		{"Gate #1", "Gate #1"},
+		{"a  b\r\n\n\n\nc\n", "a b\n\nc"},
+	}
+
+	for _, test := range tests {
+		if got := prettify(t, p, test.input); got != test.want {
+			t.Errorf("Prettify(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestPrettifyLocale(t *testing.T) {
+	input := "D(2007-04-05T09:30+03:00) T12(2007-04-05T21:30+03:00) T24(2007-04-05T09:30+03:00)"
+	tests := map[string]string{
+		"en": "05 Apr 2007 09:30PM (+03:00) 09:30 (+03:00)",
+		"et": "5. apr 2007 9:30 p.l. (+03:00) 09:30 (+03:00)",
+		"de": "5. Apr. 2007 9:30 nachm. (+03:00) 09:30 Uhr (+03:00)",
+		"fi": "5. huhtik. 2007 9.30 ip. (+03:00) 9.30 (+03:00)",
+	}
+
+	for name, want := range tests {
+		locale, err := LookupLocale(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := prettify(t, testPrettifier(t, Options{Locale: locale}), input); got != want {
+			t.Errorf("locale %s: got %q, want %q", name, got, want)
+		}
+	}
+
+	if _, err := LookupLocale("xx"); err == nil {
+		t.Error("expected an error for an unknown locale")
+	}
+}
+
+func TestPrettifyLongTokens(t *testing.T) {
+	input := "DL(2007-04-05T09:30+03:00) DT12(2007-04-05T21:30+03:00) DT24(2007-04-05T09:30Z) DUR(2007-04-05T09:30+03:00,2007-04-05T11:05+02:00)!"
+	tests := map[string]string{
+		"en": "Thursday, 05 April 2007 05 Apr 2007, 09:30PM (+03:00) 05 Apr 2007, 09:30 (+00:00) 2h 35m!",
+		"de": "Donnerstag, 5. April 2007 5. Apr. 2007, 9:30 nachm. (+03:00) 5. Apr. 2007, 09:30 Uhr (+00:00) 2 Std. 35 Min.!",
+	}
+
+	for name, want := range tests {
+		locale, _ := LookupLocale(name)
+		if got := prettify(t, testPrettifier(t, Options{Locale: locale}), input); got != want {
+			t.Errorf("locale %s: got %q, want %q", name, got, want)
+		}
+	}
+
+	backwards := "DUR(2007-04-05T11:00Z,2007-04-05T09:00Z)"
+	if got := prettify(t, testPrettifier(t, Options{}), backwards); got != backwards {
+		t.Errorf("a negative duration should be left alone, got %q", got)
+	}
+}
+
+const zonedLookup = `name,iso_country,municipality,icao_code,iata_code,coordinates,timezone
+Helsinki Vantaa Airport,FI,Helsinki,EFHK,HEL,"24.963300704956, 60.317199707031",Europe/Helsinki
+John F Kennedy International Airport,US,New York,KJFK,JFK,"-73.778900146484, 40.63980103",America/New_York
+Dubai International Airport,AE,Dubai,OMDB,DXB,"55.3643989563, 25.2527999878",Asia/Dubai
+`
+
+func TestPrettifyTimezones(t *testing.T) {
+	airports, err := LoadAirports(strings.NewReader(zonedLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		options Options
+		input   string
+		want    string
+	}{
+		{Options{}, "T24(2024-07-01T09:30Z@HEL)", "12:30 (+03:00)"},
+		{Options{ZoneNames: true}, "T24(2024-07-01T09:30Z@HEL) T12(2024-01-01T09:30Z@KJFK)", "12:30 (EEST) 04:30AM (EST)"},
+		{Options{ZoneNames: true}, "#HEL T24(2024-07-01T09:30+03:00), #JFK T24(2024-07-01T09:30-04:00)", "Helsinki Vantaa Airport 09:30 (EEST), John F Kennedy International Airport 09:30 (EDT)"},
+		{Options{ZoneNames: true}, "#HEL T24(2024-07-01T09:30+05:00)", "Helsinki Vantaa Airport 09:30 (+05:00)"},
+		{Options{ZoneNames: true}, "DT24(2024-07-01T22:30Z@DXB)", "02 Jul 2024, 02:30 (Asia/Dubai)"},
+		{Options{}, "T24(2024-07-01T09:30Z@XXX)", "T24(2024-07-01T09:30Z@XXX)"},
+	}
+
+	for _, test := range tests {
+		if got := prettify(t, New(airports, test.options), test.input); got != test.want {
+			t.Errorf("Prettify(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+
+	_, err = LoadAirports(strings.NewReader(strings.Replace(zonedLookup, "Asia/Dubai", "Asia/Nowhere", 1)))
+	if err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+}
+
+func TestPrettifyFormats(t *testing.T) {
+	input := "From #HEL, on T24(2024-07-01T09:30+03:00) <now>\nTo *#TLL_"
+	tests := map[Format]string{
+		FormatText: "From Helsinki Vantaa Airport, on 09:30 (+03:00) <now>\nTo Tallinn_",
+		FormatHTML: "<div class=\"itinerary\">\n" +
+			`From <abbr class="airport" title="HEL">Helsinki Vantaa Airport</abbr>, on <time class="time" datetime="2024-07-01T09:30+03:00">09:30 (+03:00)</time> &lt;now&gt;<br>` +
+			"\n" + `To <abbr class="city" title="TLL">Tallinn</abbr>_` + "\n</div>\n",
+		FormatMarkdown: "From **Helsinki Vantaa Airport**, on **09:30 (+03:00)** \\<now\\>  \nTo **Tallinn**\\_",
+	}
+
+	for format, want := range tests {
+		if got := prettify(t, testPrettifier(t, Options{Format: format}), input); got != want {
+			t.Errorf("format %s: got %q, want %q", format, got, want)
+		}
+	}
+
+	distance := prettify(t, testPrettifier(t, Options{Format: FormatHTML}), "DIST(HEL,TLL)")
+	if want := "<div class=\"itinerary\">\n" + `<span class="distance" title="HEL-TLL">101 km (63 mi)</span>` + "\n</div>\n"; distance != want {
+		t.Errorf("html distance: got %q, want %q", distance, want)
+	}
+
+	var document jsonDocument
+	output := prettify(t, testPrettifier(t, Options{Format: FormatJSON}), input)
+	if err := json.Unmarshal([]byte(output), &document); err != nil {
+		t.Fatal(err)
+	}
+	want := []Entity{
+		{Kind: KindAirport, Source: "#HEL", Value: "Helsinki Vantaa Airport", Line: 1, Column: 6, EndColumn: 10, Code: "HEL"},
+		{Kind: KindTime, Source: "T24(2024-07-01T09:30+03:00)", Value: "09:30 (+03:00)", Line: 1, Column: 15, EndColumn: 42, Datetime: "2024-07-01T09:30+03:00"},
+		{Kind: KindCity, Source: "*#TLL", Value: "Tallinn", Line: 2, Column: 4, EndColumn: 9, Code: "TLL"},
+	}
+	if !reflect.DeepEqual(document.Entities, want) {
+		t.Errorf("json entities = %+v, want %+v", document.Entities, want)
+	}
+
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestPrettifyDiagnostics(t *testing.T) {
+	input := "From #HLE to ##EETN\non D(2022-13-09T08:07Z), T24(2022-01-09 at gate #12"
+	want := []Diagnostic{
+		{Line: 1, Column: 6, Token: "#HLE", Message: "unknown airport code", Suggestions: []string{"#HEL"}},
+		{Line: 2, Column: 4, Token: "D(2022-13-09T08:07Z)", Message: "invalid date or time"},
+		{Line: 2, Column: 26, Token: "T24(2022-01-09", Message: "malformed date or time"},
+		{Line: 2, Column: 49, Token: "#12", Message: "unknown airport code"},
+	}
+
+	var output, warnings bytes.Buffer
+	err := testPrettifier(t, Options{Strict: true}).Prettify(strings.NewReader(input), &output)
+	var diagnostics *DiagnosticsError
+	if !errors.As(err, &diagnostics) || !reflect.DeepEqual(diagnostics.Diagnostics, want) {
+		t.Fatalf("strict mode returned %v, want %+v", err, want)
+	}
+	if output.Len() != 0 {
+		t.Errorf("strict mode wrote output %q", output.String())
+	}
+
+	report, err := testPrettifier(t, Options{Warnings: &warnings}).PrettifyReport(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Lines != 2 || report.Resolved != 1 || len(report.Diagnostics) != len(want) {
+		t.Errorf("report = %+v", report)
+	}
+	if got := strings.Count(warnings.String(), "warning: line "); got != len(want) {
+		t.Errorf("expected %d warnings, got %q", len(want), warnings.String())
+	}
+	if !strings.Contains(warnings.String(), `line 1, col 6: unknown airport code "#HLE"`) {
+		t.Errorf("warnings do not report #HLE: %q", warnings.String())
+	}
+}
+
+type chanWriter chan string
+
+func (c chanWriter) Write(data []byte) (int, error) {
+	c <- string(data)
+	return len(data), nil
+}
+
+func TestPrettifyStreams(t *testing.T) {
+	reader, writer := io.Pipe()
+	output := make(chanWriter, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- testPrettifier(t, Options{}).Prettify(reader, output)
+	}()
+
+	// The first line has to come out while the input is still open.
+	writer.Write([]byte("From #HEL\n"))
+	select {
+	case got := <-output:
+		if got != "From Helsinki Vantaa Airport" {
+			t.Errorf("first line = %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output before the end of the input")
+	}
+
+	writer.Write([]byte("to #TLL\n"))
+	writer.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := <-output; got != "\nto Lennart Meri Tallinn Airport" {
+		t.Errorf("second line = %q", got)
+	}
+}
+
+func TestPrettifyDistance(t *testing.T) {
+	input := "From #HEL to *#TLL, DIST(HEL,EETN).\nThen ##EETN to #HAJ and DIST(HEL,XXX)"
+	want := "From Helsinki Vantaa Airport to Tallinn, 101 km (63 mi).\n" +
+		"Then Lennart Meri Tallinn Airport to Hannover Airport and DIST(HEL,XXX)\n" +
+		"\n" +
+		"Flight legs:\n" +
+		"Helsinki Vantaa Airport (HEL) → Lennart Meri Tallinn Airport (TLL), 101 km (63 mi)\n" +
+		"Lennart Meri Tallinn Airport (TLL) → Hannover Airport (HAJ), 1215 km (755 mi)\n" +
+		"Total: 1316 km (817 mi)"
+
+	var output bytes.Buffer
+	report, err := testPrettifier(t, Options{Legs: true}).PrettifyReport(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != want {
+		t.Errorf("got %q, want %q", output.String(), want)
+	}
+	if len(report.Legs) != 2 || len(report.Diagnostics) != 1 || report.Diagnostics[0].Message != "unknown airport XXX" {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestPrettifyTemplates(t *testing.T) {
+	options := Options{AirportTemplate: "{name} ({code}), {country}", CityTemplate: "{city}, {country_code} ({iata}/{icao})"}
+	input := "From ##EETN to #HEL via *#HAJ."
+	want := "From Lennart Meri Tallinn Airport (EETN), Estonia to Helsinki Vantaa Airport (HEL), Finland via Hannover, DE (HAJ/EDDV)."
+	if got := prettify(t, testPrettifier(t, options), input); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if err := CheckTemplate(options.AirportTemplate); err != nil {
+		t.Error(err)
+	}
+	if err := CheckTemplate("{nmae} ({iata})"); err == nil {
+		t.Error("expected an error for an unknown placeholder")
+	}
+}
+
+func TestPrettifySuggestions(t *testing.T) {
+	tests := []struct {
+		token string
+		want  []string
+	}{
+		{"#HLE", []string{"#HEL"}},
+		{"#hel", []string{"#HEL"}},
+		{"##EFKH", []string{"##EFHK"}},
+		{"#Tallinn", []string{"#TLL"}},
+		{"*#hann", []string{"*#HAJ"}},
+		{"#XYZ", nil},
+	}
+	p := testPrettifier(t, Options{})
+	for _, test := range tests {
+		if got := p.airports.suggest(test.token); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("suggest(%q) = %q, want %q", test.token, got, test.want)
+		}
+	}
+
+	// An exact match in another case comes before the codes one edit away, even when they sort before it.
+	crowded, err := LoadAirports(strings.NewReader(`name,iso_country,municipality,icao_code,iata_code,coordinates
+Belfast Airport,GB,Belfast,EGAA,BEL,"-6.2, 54.6"
+Delhi Airport,IN,Delhi,VIDP,DEL,"77.1, 28.5"
+Ehl Field,DE,Ehl,EDXE,EHL,"8.0, 49.0"
+Helsinki Vantaa Airport,FI,Helsinki,EFHK,HEL,"24.963300704956, 60.317199707031"
+Heh Field,DE,Heh,EDXH,HEH,"8.0, 49.0"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := crowded.suggest("#hel"); len(got) == 0 || got[0] != "#HEL" {
+		t.Errorf("suggest(%q) = %q, want #HEL first", "#hel", got)
+	}
+	if got := prettify(t, New(crowded, Options{AutoCorrect: true}), "to #hel"); got != "to Helsinki Vantaa Airport" {
+		t.Errorf("autocorrect of #hel gave %q", got)
+	}
+
+	input := "From #Tallinn, to #HLE and #XYZ."
+	var output bytes.Buffer
+	report, err := testPrettifier(t, Options{AutoCorrect: true, Strict: true}).PrettifyReport(strings.NewReader(input), &output)
+	var diagnostics *DiagnosticsError
+	if !errors.As(err, &diagnostics) || len(diagnostics.Diagnostics) != 1 || diagnostics.Diagnostics[0].Token != "#XYZ" {
+		t.Fatalf("strict mode returned %v, want only #XYZ", err)
+	}
+	if len(report.Diagnostics) != 3 || report.Diagnostics[1].String() != `line 1, col 19: unknown airport code "#HLE", corrected to #HEL` {
+		t.Errorf("report = %+v", report.Diagnostics)
+	}
+
+	want := "From Lennart Meri Tallinn Airport, to Helsinki Vantaa Airport and #XYZ."
+	if got := prettify(t, testPrettifier(t, Options{AutoCorrect: true}), input); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPrettifyWhitespace(t *testing.T) {
+	input := "Flight  | From\r\nAY 1  | #HEL\r\n\r\n\r\n\r\nend\vpage"
+	tests := map[string]string{
+		"classic":  "Flight | From\n\nAY 1 | Helsinki Vantaa Airport\n\nend\npage",
+		"compact":  "Flight | From\nAY 1 | Helsinki Vantaa Airport\n\nend\npage",
+		"preserve": "Flight  | From\nAY 1  | Helsinki Vantaa Airport\n\n\n\nend\vpage",
+	}
+	for name, want := range tests {
+		rules, err := LookupWhitespace(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := prettify(t, testPrettifier(t, Options{Whitespace: rules}), input); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	rules := &Whitespace{CRLF: true, MaxBlankLines: 2}
+	if got, want := prettify(t, testPrettifier(t, Options{Whitespace: rules}), input), "Flight  | From\nAY 1  | Helsinki Vantaa Airport\n\n\nend\vpage"; got != want {
+		t.Errorf("two blank lines: got %q, want %q", got, want)
+	}
+	// At the start of the input one more blank line is kept, as the program has always done.
+	if got, want := prettify(t, testPrettifier(t, Options{}), "\n\n\n\nX\v"), "\n\nX"; got != want {
+		t.Errorf("leading blank lines: got %q, want %q", got, want)
+	}
+	if _, err := LookupWhitespace("tabs"); err == nil {
+		t.Error("expected an error for an unknown whitespace profile")
+	}
+}
+
+func TestPrettifyLexer(t *testing.T) {
+	input := `Fly (#HEL) to "*#TLL"; ##EETN: at T24(2022-05-09T08:07+03:00); [D(2022-05-09T08:07Z)]... x#HEL #HELsinki #HAJé`
+	want := `Fly (Helsinki Vantaa Airport) to "Tallinn"; Lennart Meri Tallinn Airport: at 08:07 (+03:00); [09 May 2022]... x#HEL #HELsinki #HAJé`
+	p := testPrettifier(t, Options{})
+	if got := prettify(t, p, input); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	segments, diagnostics := p.processText(1, input)
+	var columns []int
+	for _, piece := range segments {
+		if piece.entity != nil {
+			columns = append(columns, piece.entity.Column)
+		}
+	}
+	if want := []int{6, 16, 24, 35, 65}; !reflect.DeepEqual(columns, want) || len(diagnostics) != 2 || diagnostics[0].Token != "#HELsinki" || diagnostics[1].Token != "#HAJé" {
+		t.Errorf("columns = %v with %v, want %v", columns, diagnostics, want)
+	}
+}
+
+func TestPrettifyEscapes(t *testing.T) {
+	input := `Gate \#12 and D\(2022-05-09T08:07Z) at #HEL, not x#HEL or \\#HEL, C:\temp`
+	want := `Gate #12 and D(2022-05-09T08:07Z) at Helsinki Vantaa Airport, not x#HEL or \Helsinki Vantaa Airport, C:\temp`
+
+	var output bytes.Buffer
+	report, err := testPrettifier(t, Options{Strict: true}).PrettifyReport(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != want || report.Resolved != 2 {
+		t.Errorf("got %q with %d tokens, want %q", output.String(), report.Resolved, want)
+	}
+
+	// Encoding escapes the text that would be read as a token and prettifying removes the escapes again.
+	p := testPrettifier(t, Options{})
+	var encoded bytes.Buffer
+	if err := p.Encode(strings.NewReader(want), &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if again := prettify(t, p, encoded.String()); again != want {
+		t.Errorf("round trip gave %q from %q, want %q", again, encoded.String(), want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		locale string
+		input  string
+		want   string
+	}{
+		{"en", "departing Helsinki at 09:30 (+03:00), arriving Lennart Meri Tallinn Airport.",
+			"departing Helsinki at 09:30 (+03:00), arriving #TLL."},
+		{"en", "On 09 May 2022 at 07:18PM (-02:00)\nand Monday, 09 May 2022, 08:07 (Z) in Hannoverland",
+			"On D(2022-05-09T00:00Z) at T12(2022-05-09T19:18-02:00)\nand DL(2022-05-09T00:00Z), 08:07 (Z) in Hannoverland"},
+		{"et", "Lend 9. mai 2022 kell 19:18 (+03:00), Hannover Airport",
+			"Lend DT24(2022-05-09T19:18+03:00), #HAJ"},
+	}
+
+	for _, test := range tests {
+		locale, err := LookupLocale(test.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var output bytes.Buffer
+		if err := testPrettifier(t, Options{Locale: locale}).Encode(strings.NewReader(test.input), &output); err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != test.want {
+			t.Errorf("%s: encode(%q) = %q, want %q", test.locale, test.input, output.String(), test.want)
+		}
+	}
+
+	// A time without a date before it stays text and is reported.
+	var output, warnings bytes.Buffer
+	if err := testPrettifier(t, Options{Warnings: &warnings}).Encode(strings.NewReader("at 09:30 (+03:00)"), &output); err != nil {
+		t.Fatal(err)
+	}
+	if want := "warning: line 1, col 4: no date before time \"09:30 (+03:00)\"\n"; output.String() != "at 09:30 (+03:00)" || warnings.String() != want {
+		t.Errorf("encode = %q with warnings %q, want the time as text and %q", output.String(), warnings.String(), want)
+	}
+
+	// Municipalities are only encoded when asked for, and never when their airports are in several countries.
+	cities, err := LoadAirports(strings.NewReader(`name,iso_country,municipality,icao_code,iata_code,coordinates
+Heathrow Airport,GB,London,EGLL,LHR,"-0.46, 51.47"
+London International Airport,CA,London,CYXU,YXU,"-81.15, 43.03"
+Nice Côte d'Azur Airport,FR,Nice,LFMN,NCE,"7.21, 43.66"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "Nice weather, arrive in London"
+	for _, test := range []struct {
+		cities bool
+		want   string
+	}{
+		{false, "Nice weather, arrive in London"},
+		{true, "*#NCE weather, arrive in London"},
+	} {
+		var encoded bytes.Buffer
+		if err := New(cities, Options{EncodeCities: test.cities}).Encode(strings.NewReader(input), &encoded); err != nil {
+			t.Fatal(err)
+		}
+		if encoded.String() != test.want {
+			t.Errorf("encode with cities %v = %q, want %q", test.cities, encoded.String(), test.want)
+		}
+	}
+
+	// Encoding the prettified text gives tokens that prettify to the same text.
+	p := testPrettifier(t, Options{EncodeCities: true})
+	pretty := prettify(t, p, "From ##EFHK on DT24(2022-05-09T08:07+03:00) to *#TLL.")
+	var encoded bytes.Buffer
+	if err := p.Encode(strings.NewReader(pretty), &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if again := prettify(t, p, encoded.String()); again != pretty {
+		t.Errorf("round trip gave %q from %q, want %q", again, encoded.String(), pretty)
+	}
+}
+
+func TestWriteCalendar(t *testing.T) {
+	input := "Flight #HEL to #TLL; departs T24(2022-05-09T08:07+03:00), lands T24(2022-05-09T09:00+03:00)\n" +
+		"Stay in *#TLL on D(2022-05-10T00:00Z)\nNo airport on D(2022-05-11T00:00Z)"
+	var output bytes.Buffer
+	report, err := testPrettifier(t, Options{}).PrettifyReport(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Events) != 2 || report.Events[0].Summary != "Helsinki Vantaa Airport → Lennart Meri Tallinn Airport" || !report.Events[1].AllDay {
+		t.Fatalf("events = %+v", report.Events)
+	}
+
+	var calendar bytes.Buffer
+	if err := WriteCalendar(&calendar, report.Events); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20220509T050700Z\r\nDTEND:20220509T060000Z\r\n",
+		"LOCATION:Helsinki Vantaa Airport\r\n",
+		"DESCRIPTION:Flight Helsinki Vantaa Airport to Lennart Meri Tallinn Airport\\; departs 08:07 (+03:00)\\, lands 09:00 (+03:00)\r\n",
+		"DTSTART;VALUE=DATE:20220510\r\nSUMMARY:Tallinn\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(strings.ReplaceAll(calendar.String(), "\r\n ", ""), want) {
+			t.Errorf("calendar does not contain %q:\n%s", want, calendar.String())
+		}
+	}
+	for _, line := range strings.Split(calendar.String(), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 bytes: %q", line)
+		}
+	}
+}
+
+func TestMergeAirports(t *testing.T) {
+	public, err := LoadAirports(strings.NewReader(testLookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := LoadAirports(strings.NewReader(`name,iso_country,municipality,icao_code,iata_code,coordinates
+Helsinki Terminal 2,FI,Helsinki,EFHK,HEL,"24.96, 60.31"
+Hannover Airport,DE,Hannover,EDDV,HAJ,"9.685079574580001, 52.461101532"
+Koivulahti Airfield,FI,Mustasaari,EFXX,XKV,"21.9, 63.1"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, conflicts := MergeAirports(public, overrides)
+	if len(conflicts) != 2 || conflicts[0].Code != "EFHK" || conflicts[1].Code != "HEL" || conflicts[1].Replaced.Name != "Helsinki Vantaa Airport" {
+		t.Errorf("conflicts = %v, want EFHK and HEL", conflicts)
+	}
+	want := "From Helsinki Terminal 2 via Koivulahti Airfield to Lennart Meri Tallinn Airport and Helsinki"
+	if got := prettify(t, New(merged, Options{}), "From #HEL via ##EFXX to #TLL and *#HEL"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if airports := merged.Municipality("Helsinki"); len(airports) != 1 || airports[0].Name != "Helsinki Terminal 2" {
+		t.Errorf("Helsinki has airports %v", airports)
+	}
+
+	// The other way around the public dataset wins.
+	merged, _ = MergeAirports(overrides, public)
+	if found, _ := merged.IATA("HEL"); found.Name != "Helsinki Vantaa Airport" {
+		t.Errorf("HEL is %q", found.Name)
+	}
+}
+
+func TestLoadAirportsColumns(t *testing.T) {
+	reordered := "timezone,iata_code,coordinates,Name,elevation,icao_code,municipality,iso_country\n" +
+		"Europe/Helsinki,HEL,\"24.96, 60.31\",Helsinki Vantaa Airport,179,EFHK,Helsinki,FI\n"
+	airports, err := LoadAirports(strings.NewReader(reordered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if airport, ok := airports.ICAO("EFHK"); !ok || airport.Name != "Helsinki Vantaa Airport" {
+		t.Errorf("ICAO(EFHK) = %v, %v", airport, ok)
+	}
+
+	_, err = LoadAirports(strings.NewReader("name,iso_country,municipality,icao_code,coordinates\n"))
+	var missing *MissingColumnError
+	if !errors.As(err, &missing) || missing.Column != "iata_code" {
+		t.Errorf("expected missing iata_code column, got %v", err)
+	}
+}
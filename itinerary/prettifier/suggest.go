@@ -0,0 +1,104 @@
+package prettifier
+
+import (
+	"sort"
+	"strings"
+)
+
+// The most suggestions given for one unknown code.
+const maxSuggestions = 5
+
+// suggest lists the codes that the unknown token was most likely meant to be, written with the same prefix. A code that
+// only differs in case comes first, then the codes that are one edit away, a swap of two neighbouring letters counting
+// as one edit, then the airports of a municipality with the same name and last the municipalities that start with the
+// letters of the token.
+func (a *Airports) suggest(token string) []string {
+	letters := strings.TrimLeft(token, "*#")
+	prefix := token[:len(token)-len(letters)]
+	icao := strings.HasSuffix(prefix, "##")
+	typed := strings.ToUpper(letters)
+
+	codes := a.byIATA
+	if icao {
+		codes = a.byICAO
+	}
+
+	distances := make(map[string]int)
+	var close []string
+	for code := range codes {
+		if len(code) != len(typed) {
+			continue
+		}
+		if distance := editDistance(typed, code); distance <= 1 {
+			distances[prefix+code] = distance
+			close = append(close, prefix+code)
+		}
+	}
+	sort.Slice(close, func(i, j int) bool {
+		if distances[close[i]] != distances[close[j]] {
+			return distances[close[i]] < distances[close[j]]
+		}
+		return close[i] < close[j]
+	})
+
+	var named, started []string
+	for municipality, airports := range a.byMunicipality {
+		upper := strings.ToUpper(municipality)
+		exact := upper == typed
+		if !exact && (len(typed) < 3 || !strings.HasPrefix(upper, typed)) {
+			continue
+		}
+		for _, airport := range airports {
+			code := airport.IATA
+			if icao {
+				code = airport.ICAO
+			}
+			if code == "" {
+				continue
+			}
+			if exact {
+				named = append(named, prefix+code)
+			} else {
+				started = append(started, prefix+code)
+			}
+		}
+	}
+	sort.Strings(named)
+	sort.Strings(started)
+
+	var suggestions []string
+	seen := make(map[string]bool)
+	for _, candidate := range append(append(close, named...), started...) {
+		if !seen[candidate] && len(suggestions) < maxSuggestions {
+			seen[candidate] = true
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions
+}
+
+// editDistance is the optimal string alignment distance of two strings of ASCII letters.
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := 0; j <= len(b); j++ {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
@@ -0,0 +1,77 @@
+package prettifier
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The country names come from the same ISO 3166 table the cars-viewer uses.
+//
+//go:embed country-codes.csv
+var countryTable string
+
+var countryNames = loadCountries(countryTable)
+
+// The placeholders an airport template can use.
+var airportPlaceholders = []string{"{name}", "{city}", "{iata}", "{icao}", "{code}", "{country}", "{country_code}"}
+
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+func loadCountries(table string) map[string]string {
+	names := make(map[string]string)
+	records, err := csv.NewReader(strings.NewReader(table)).ReadAll()
+	if err != nil {
+		return names
+	}
+	for _, record := range records[1:] {
+		names[record[1]] = record[0]
+	}
+	return names
+}
+
+// CheckTemplate reports the first placeholder of an airport template that does not exist, such as {nmae}.
+func CheckTemplate(template string) error {
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		known := false
+		for _, candidate := range airportPlaceholders {
+			known = known || placeholder == candidate
+		}
+		if !known {
+			return fmt.Errorf("unknown placeholder %s, expected one of %s", placeholder, strings.Join(airportPlaceholders, " "))
+		}
+	}
+	return nil
+}
+
+// airportValue renders a resolved airport with the airport template, or the city template for a *# word. code is the
+// code as it was written, without the # signs.
+func (p *Prettifier) airportValue(found *Airport, code string, city bool) string {
+	template := p.options.AirportTemplate
+	if template == "" {
+		template = "{name}"
+	}
+	if city {
+		template = p.options.CityTemplate
+		if template == "" {
+			template = "{city}"
+		}
+	}
+
+	country, ok := countryNames[found.Country]
+	if !ok {
+		country = found.Country
+	}
+	replacer := strings.NewReplacer(
+		"{name}", found.Name,
+		"{city}", found.Municipality,
+		"{iata}", found.IATA,
+		"{icao}", found.ICAO,
+		"{code}", code,
+		"{country}", country,
+		"{country_code}", found.Country,
+	)
+	return replacer.Replace(template)
+}
@@ -0,0 +1,195 @@
+package prettifier
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The kinds of Entity.
+const (
+	KindAirport  = "airport"
+	KindCity     = "city"
+	KindDate     = "date"
+	KindTime     = "time"
+	KindDateTime = "datetime"
+	KindDuration = "duration"
+	KindDistance = "distance"
+)
+
+// Entity is a token of the itinerary that was recognized and resolved.
+type Entity struct {
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`             // The token as it was written, without trailing punctuation.
+	Value     string `json:"value"`              // The text that replaces the token.
+	Line      int    `json:"line"`               // Line of the token, counted from 1.
+	Column    int    `json:"column"`             // Character the token starts at, counted from 1.
+	EndColumn int    `json:"end_column"`         // Character right after the token, counted from 1.
+	Code      string `json:"code,omitempty"`     // IATA or ICAO code of an airport or city.
+	Datetime  string `json:"datetime,omitempty"` // Machine readable ISO 8601 form of a date, time or duration.
+
+	airport *Airport
+}
+
+// segment is a piece of a processed line, either plain text or a resolved entity.
+type segment struct {
+	text   string
+	entity *Entity
+}
+
+var (
+	spacePattern = regexp.MustCompile(` {2,}`)
+	breakPattern = regexp.MustCompile(`[\v\f\r]+`)
+)
+
+// inputReader hands out the normalized lines of the input one at a time, so a large file or a pipe is never held in
+// memory as a whole.
+type inputReader struct {
+	reader  *bufio.Reader
+	rules   *Whitespace
+	pending []string
+	blanks  int
+	done    bool
+}
+
+func inputRead(input io.Reader, rules *Whitespace) *inputReader {
+	// No line came before the first blank lines, so one more of them is kept, the way the program has always read them.
+	return &inputReader{reader: bufio.NewReader(input), rules: rules, blanks: -1}
+}
+
+// next returns the next line of the input and false once the input has ended. The lines are normalized with the
+// Whitespace rules of the reader.
+func (r *inputReader) next() (string, bool, error) {
+	for {
+		if len(r.pending) > 0 {
+			line := r.pending[0]
+			r.pending = r.pending[1:]
+			if line != "" {
+				r.blanks = 0
+				return line, true, nil
+			}
+			if r.rules.MaxBlankLines >= 0 && r.blanks >= r.rules.MaxBlankLines {
+				continue
+			}
+			r.blanks++
+			return line, true, nil
+		}
+		if r.done {
+			return "", false, nil
+		}
+
+		raw, err := r.reader.ReadString('\n')
+		if err == io.EOF {
+			r.done = true
+			if raw == "" {
+				continue
+			}
+		} else if err != nil {
+			return "", false, err
+		}
+
+		raw = strings.TrimSuffix(raw, "\n")
+		if r.rules.CRLF {
+			raw = strings.TrimSuffix(raw, "\r")
+		}
+		if r.rules.CollapseSpaces {
+			raw = spacePattern.ReplaceAllString(raw, " ")
+		}
+		if r.rules.BreakControls {
+			r.pending = breakPattern.Split(raw, -1)
+			// A break at the very end of the input ends the last line, the same as a newline there.
+			if r.done && len(r.pending) > 1 && r.pending[len(r.pending)-1] == "" {
+				r.pending = r.pending[:len(r.pending)-1]
+			}
+		} else {
+			r.pending = []string{raw}
+		}
+	}
+}
+
+// processText resolves the tokens of one line, number is the line number counted from 1. It returns the segments of the
+// line and a diagnostic for every token that could not be resolved.
+func (p *Prettifier) processText(number int, line string) ([]segment, []Diagnostic) {
+	var diagnostics []Diagnostic
+	lexemes := lex(line)
+
+	var nearby []*Airport
+	for _, piece := range lexemes {
+		if found := p.airports.lookup(piece.text); piece.token && found != nil && found.location != nil {
+			nearby = append(nearby, found)
+		}
+	}
+
+	var segments []segment
+	for _, piece := range lexemes {
+		if !piece.token {
+			segments = append(segments, segment{text: piece.text})
+			continue
+		}
+		column := utf8.RuneCountInString(line[:piece.start]) + 1
+		token, diagnostic := p.processToken(piece.text, nearby)
+		if token[0].entity != nil {
+			token[0].entity.Line = number
+			token[0].entity.Column = column
+			token[0].entity.EndColumn = column + utf8.RuneCountInString(piece.text)
+		}
+		if diagnostic != nil {
+			diagnostic.Line = number
+			diagnostic.Column = column
+			diagnostics = append(diagnostics, *diagnostic)
+		}
+		segments = append(segments, token...)
+	}
+	return segments, diagnostics
+}
+
+// processToken resolves a token found by lex. A token that cannot be resolved is returned as plain text together with
+// a diagnostic, without its position. The entity, when there is one, is always the first segment. An airport code
+// followed by more letters or digits, like #HELsinki, is unknown.
+func (p *Prettifier) processToken(token string, nearby []*Airport) ([]segment, *Diagnostic) {
+	if distanceToken.MatchString(token) {
+		entity, err := p.formatDistance(token)
+		if err != nil {
+			return []segment{{text: token}}, &Diagnostic{Token: token, Message: err.Error()}
+		}
+		entity.Source = token
+		return []segment{{entity: &entity}}, nil
+	}
+
+	if tokenPattern.MatchString(token) {
+		entity, err := p.formatTime(token, nearby)
+		if err != nil {
+			return []segment{{text: token}}, &Diagnostic{Token: token, Message: err.Error()}
+		}
+		entity.Source = token
+		return []segment{{entity: &entity}}, nil
+	}
+
+	code, found := p.airports.airportRead(token)
+	var diagnostic *Diagnostic
+	source := code
+
+	if found == nil {
+		source = token
+		diagnostic = &Diagnostic{Token: token, Message: "unknown airport code", Suggestions: p.airports.suggest(token)}
+		// A code that was only written in the wrong case is corrected even when there are other suggestions.
+		suggestions := diagnostic.Suggestions
+		if p.options.AutoCorrect && (len(suggestions) == 1 || (len(suggestions) > 1 && strings.EqualFold(suggestions[0], token))) {
+			diagnostic.Correction = diagnostic.Suggestions[0]
+			code, found = p.airports.airportRead(diagnostic.Correction)
+		}
+	}
+	if found == nil {
+		return []segment{{text: token}}, diagnostic
+	}
+
+	city := strings.HasPrefix(code, "*")
+	entity := Entity{Kind: KindAirport, Source: source, Code: strings.TrimLeft(code, "*#"), airport: found}
+	entity.Value = p.airportValue(found, entity.Code, city)
+	if city {
+		entity.Kind = KindCity
+	}
+	return []segment{{entity: &entity}}, diagnostic
+}
@@ -0,0 +1,128 @@
+package prettifier
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const isoLayout = "2006-01-02T15:04Z07:00"
+
+const isoPattern = `(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(?:Z|[-+]\d{2}:\d{2}))`
+
+var (
+	timePattern     = regexp.MustCompile(`^(D|DL|DT12|DT24|T12|T24)\(` + isoPattern + `(?:@([A-Z0-9]{3,4}))?\)$`)
+	durationPattern = regexp.MustCompile(`^DUR\(` + isoPattern + `,` + isoPattern + `\)$`)
+	tokenPattern    = regexp.MustCompile(`^(D|DL|DT12|DT24|T12|T24|DUR)\(`)
+)
+
+func (p *Prettifier) formatTime(raw string, nearby []*Airport) (Entity, error) {
+	locale := p.locale()
+	if match := durationPattern.FindStringSubmatch(raw); match != nil {
+		return formatDuration(match[1], match[2], locale)
+	}
+
+	match := timePattern.FindStringSubmatch(raw)
+	if match == nil {
+		return Entity{}, errors.New("malformed date or time")
+	}
+	moment, err := time.Parse(isoLayout, match[2])
+	if err != nil {
+		return Entity{}, errors.New("invalid date or time")
+	}
+
+	location, err := p.zone(moment, match[3], nearby)
+	if err != nil {
+		return Entity{}, err
+	}
+	if location != nil {
+		moment = moment.In(location)
+	}
+
+	// The offset is written the same way in every locale, Zulu time becomes +00:00.
+	offset := " (" + moment.Format("-07:00") + ")"
+	if location != nil && p.options.ZoneNames {
+		offset = " (" + zoneLabel(moment, location) + ")"
+	}
+
+	switch match[1] {
+	case "D":
+		return Entity{Kind: KindDate, Value: locale.render(locale.Date, moment), Datetime: moment.Format("2006-01-02")}, nil
+	case "DL":
+		return Entity{Kind: KindDate, Value: locale.render(locale.LongDate, moment), Datetime: moment.Format("2006-01-02")}, nil
+	case "T12":
+		return Entity{Kind: KindTime, Value: locale.render(locale.Time12, moment) + offset, Datetime: moment.Format(isoLayout)}, nil
+	case "T24":
+		return Entity{Kind: KindTime, Value: locale.render(locale.Time24, moment) + offset, Datetime: moment.Format(isoLayout)}, nil
+	}
+
+	clock := locale.Time24
+	if match[1] == "DT12" {
+		clock = locale.Time12
+	}
+	joined := strings.NewReplacer(
+		"{date}", locale.render(locale.Date, moment),
+		"{time}", locale.render(clock, moment)+offset,
+	)
+	return Entity{Kind: KindDateTime, Value: joined.Replace(locale.DateTime), Datetime: moment.Format(isoLayout)}, nil
+}
+
+func formatDuration(from, to string, locale *Locale) (Entity, error) {
+	start, err := time.Parse(isoLayout, from)
+	if err != nil {
+		return Entity{}, errors.New("invalid date or time")
+	}
+	end, err := time.Parse(isoLayout, to)
+	if err != nil {
+		return Entity{}, errors.New("invalid date or time")
+	}
+	if end.Before(start) {
+		return Entity{}, errors.New("duration ends before it starts")
+	}
+
+	length := end.Sub(start)
+	// ISO 8601 duration, the form a HTML time element expects.
+	machine := fmt.Sprintf("PT%dH%dM", int(length.Hours()), int(length.Minutes())%60)
+	return Entity{Kind: KindDuration, Value: locale.renderDuration(length), Datetime: machine}, nil
+}
+
+// zone picks the timezone a time is shown in. A reference like @HEL or @EFHK converts the time into the zone of
+// that airport. Without one, and only when zone names are asked for, the zone of an airport on the same line is
+// used if its offset at that moment matches the offset of the timestamp.
+func (p *Prettifier) zone(moment time.Time, reference string, nearby []*Airport) (*time.Location, error) {
+	if reference != "" {
+		found, ok := p.airports.IATA(reference)
+		if len(reference) == 4 {
+			found, ok = p.airports.ICAO(reference)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown airport @%s", reference)
+		}
+		if found.location == nil {
+			return nil, fmt.Errorf("airport @%s has no timezone", reference)
+		}
+		return found.location, nil
+	}
+
+	if !p.options.ZoneNames {
+		return nil, nil
+	}
+	_, offset := moment.Zone()
+	for _, airport := range nearby {
+		if _, local := moment.In(airport.location).Zone(); local == offset {
+			return airport.location, nil
+		}
+	}
+	return nil, nil
+}
+
+// zoneLabel gives the abbreviation of the zone, such as EEST, or the IANA name when the zone has no abbreviation.
+func zoneLabel(moment time.Time, location *time.Location) string {
+	name, _ := moment.In(location).Zone()
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		return location.String()
+	}
+	return name
+}
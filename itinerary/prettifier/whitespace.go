@@ -0,0 +1,40 @@
+package prettifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Whitespace holds the rules inputRead normalizes the lines of the input with.
+type Whitespace struct {
+	CollapseSpaces bool // Runs of spaces become a single space, which breaks aligned tables.
+	CRLF           bool // A \r\n ends the line like a \n, instead of the \r adding a blank line.
+	BreakControls  bool // The \v, \f and \r characters break the line like a newline.
+	// MaxBlankLines is the number of blank lines handed out in a row, the rest are dropped. At the start of the input
+	// one more is kept, as the line breaks in a row are what is counted and there is no line before the first one. A
+	// negative number means no limit.
+	MaxBlankLines int
+}
+
+// WhitespaceProfiles holds the built-in rules by name. Classic is the default and the way the program has always
+// read its input.
+var WhitespaceProfiles = map[string]*Whitespace{
+	"classic":  {CollapseSpaces: true, BreakControls: true, MaxBlankLines: 1},
+	"compact":  {CollapseSpaces: true, CRLF: true, BreakControls: true, MaxBlankLines: 1},
+	"preserve": {CRLF: true, MaxBlankLines: -1},
+}
+
+// LookupWhitespace returns the built-in rules with the name, for example "preserve".
+func LookupWhitespace(name string) (*Whitespace, error) {
+	rules, ok := WhitespaceProfiles[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for known := range WhitespaceProfiles {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown whitespace profile %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return rules, nil
+}
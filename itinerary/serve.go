@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"itinerary/prettifier"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The largest itinerary the server accepts, in bytes.
+const maxItinerary = 1 << 20
+
+// itineraryServer prettifies posted itineraries with the airport index it was started with.
+type itineraryServer struct {
+	airports *prettifier.Airports
+	options  prettifier.Options
+}
+
+var contentTypes = map[prettifier.Format]string{
+	prettifier.FormatText:     "text/plain; charset=utf-8",
+	prettifier.FormatHTML:     "text/html; charset=utf-8",
+	prettifier.FormatJSON:     "application/json",
+	prettifier.FormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// HandleForm serves the page with the form for pasting an itinerary.
+func (s *itineraryServer) HandleForm(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, "form.html")
+}
+
+// HandlePrettify takes the itinerary either as the raw request body or as the itinerary field of a form. The format,
+// locale, whitespace, zones, legs, strict and correct settings can be given as query or form values, otherwise the
+// server's own settings are used.
+func (s *itineraryServer) HandlePrettify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxItinerary)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		requestError(w, err)
+		return
+	}
+
+	// Tools like curl send a pasted body as a form, so the body is only read as a form when it has an itinerary field.
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := r.ParseMultipartForm(maxItinerary); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		requestError(w, err)
+		return
+	}
+	itinerary := string(body)
+	if values, ok := r.PostForm["itinerary"]; ok {
+		itinerary = values[0]
+	}
+
+	options, err := s.requestOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var output bytes.Buffer
+	report, err := prettifier.New(s.airports, options).PrettifyReport(strings.NewReader(itinerary), &output)
+	var diagnostics *prettifier.DiagnosticsError
+	if errors.As(err, &diagnostics) {
+		http.Error(w, diagnostics.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error prettifying itinerary: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[options.Format])
+	w.Header().Set("X-Unresolved-Tokens", strconv.Itoa(len(report.Diagnostics)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(output.Bytes())
+}
+
+// requestOptions starts from the server's options and applies the format, locale, whitespace, zones, legs, strict and correct
+// values of the request.
+func (s *itineraryServer) requestOptions(r *http.Request) (prettifier.Options, error) {
+	options := s.options
+	if value := r.FormValue("format"); value != "" {
+		format, err := prettifier.ParseFormat(value)
+		if err != nil {
+			return options, err
+		}
+		options.Format = format
+	}
+	if value := r.FormValue("locale"); value != "" {
+		locale, err := prettifier.LookupLocale(value)
+		if err != nil {
+			return options, err
+		}
+		options.Locale = locale
+	}
+	if value := r.FormValue("whitespace"); value != "" {
+		rules, err := prettifier.LookupWhitespace(value)
+		if err != nil {
+			return options, err
+		}
+		options.Whitespace = rules
+	}
+	if value := r.FormValue("zones"); value != "" {
+		options.ZoneNames = value == "true" || value == "on"
+	}
+	if value := r.FormValue("legs"); value != "" {
+		options.Legs = value == "true" || value == "on"
+	}
+	if value := r.FormValue("strict"); value != "" {
+		options.Strict = value == "true" || value == "on"
+	}
+	if value := r.FormValue("correct"); value != "" {
+		options.AutoCorrect = value == "true" || value == "on"
+	}
+	if options.Format == "" {
+		options.Format = prettifier.FormatText
+	}
+	options.Warnings = nil
+	return options, nil
+}
+
+func requestError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Itinerary too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Bad Request", http.StatusBadRequest)
+}
+
+// serve runs the prettifier as a web server until it gets an interrupt signal.
+func serve(address string, airports *prettifier.Airports, options prettifier.Options) {
+	handler := &itineraryServer{airports: airports, options: options}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handler.HandleForm)
+	mux.HandleFunc("/prettify", handler.HandlePrettify)
+
+	theServer := &http.Server{
+		Addr:           address,
+		Handler:        mux,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   10 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+	}
+
+	log.Printf("Starting server on %s", address)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		if err := theServer.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatalf("ListenAndServe error: %v", err)
+		}
+	}()
+
+	<-sigCh
+	log.Println("\nReceived interrupt signal. Gracefully shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := theServer.Shutdown(ctx); err != nil {
+		log.Fatal("Server shutdown error:", err)
+	}
+
+	log.Println("Server gracefully stopped")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"itinerary/prettifier"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testServer(t *testing.T) *itineraryServer {
+	t.Helper()
+	lookup := "name,iso_country,municipality,icao_code,iata_code,coordinates\n" +
+		"Helsinki Vantaa Airport,FI,Helsinki,EFHK,HEL,\"24.96, 60.31\"\n"
+	airports, err := prettifier.LoadAirports(strings.NewReader(lookup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &itineraryServer{airports: airports}
+}
+
+func TestHandlePrettify(t *testing.T) {
+	server := testServer(t)
+
+	request := httptest.NewRequest(http.MethodPost, "/prettify", strings.NewReader("From #HEL at T24(2024-07-01T09:30+03:00)"))
+	recorder := httptest.NewRecorder()
+	server.HandlePrettify(recorder, request)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "From Helsinki Vantaa Airport at 09:30 (+03:00)" {
+		t.Errorf("raw body: %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	form := url.Values{"itinerary": {"From #HEL and #XXX"}, "format": {"json"}}
+	request = httptest.NewRequest(http.MethodPost, "/prettify", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder = httptest.NewRecorder()
+	server.HandlePrettify(recorder, request)
+	var document struct {
+		Text        string
+		Diagnostics []prettifier.Diagnostic
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &document); err != nil {
+		t.Fatalf("form body: %d %q", recorder.Code, recorder.Body.String())
+	}
+	if document.Text != "From Helsinki Vantaa Airport and #XXX" || len(document.Diagnostics) != 1 || recorder.Header().Get("X-Unresolved-Tokens") != "1" {
+		t.Errorf("form body: %+v", document)
+	}
+}
+
+func TestHandlePrettifyErrors(t *testing.T) {
+	server := testServer(t)
+	tests := []struct {
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/prettify", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/prettify?format=pdf", "#HEL", http.StatusBadRequest},
+		{http.MethodPost, "/prettify?strict=true", "#XXX", http.StatusUnprocessableEntity},
+		{http.MethodPost, "/prettify", strings.Repeat("#HEL ", maxItinerary/4), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		server.HandlePrettify(recorder, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+		if recorder.Code != test.want {
+			t.Errorf("%s %s: got %d, want %d", test.method, test.target, recorder.Code, test.want)
+		}
+	}
+}